@@ -0,0 +1,447 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Network NetworkConfig
+	Server  ServerConfig
+	Storage StorageConfig
+	Tx      TxConfig
+}
+
+type NetworkConfig struct {
+	// Name is mainnet, testnet or any custom network name
+	Name       string
+	HorizonURL string
+	Passphrase string
+}
+
+type ServerConfig struct {
+	Port            string
+	ShutdownTimeout time.Duration
+
+	// TLS is enabled when both files are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TrustedProxies lists the IPs or CIDRs allowed to set X-Forwarded-*
+	// headers
+	TrustedProxies []string
+
+	// SessionTTL is how long a login stays valid
+	SessionTTL time.Duration
+
+	// AdminToken guards operator endpoints. They are disabled when empty.
+	AdminToken string
+
+	// WebhookSecret signs webhook deliveries when set
+	WebhookSecret string
+}
+
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+type StorageConfig struct {
+	// DataDir holds everything the service persists
+	DataDir string
+
+	// KeystorePassphrase encrypts the keys kept for unattended signing, such
+	// as recurring payments. Those features are disabled when empty.
+	KeystorePassphrase string
+}
+
+type TxConfig struct {
+	// ConfirmTimeout bounds how long a submission waits for its transaction
+	// to be applied before reporting it as still pending
+	ConfirmTimeout time.Duration
+
+	// Timeout is how long a transaction stays valid after it is built. Past
+	// it the transaction can provably never be applied.
+	Timeout time.Duration
+
+	// LedgerWindow additionally limits transactions to this many ledgers
+	// past the latest one. 0 disables ledger bounds.
+	LedgerWindow uint32
+
+	// FeeProfile is the default fee profile: low, normal or high
+	FeeProfile string
+
+	// MaxFee caps the fee of a single transaction and MaxFeePerDay the fees
+	// committed per UTC day, both in stroops
+	MaxFee       int64
+	MaxFeePerDay int64
+
+	// FeeAccountSecret is the secret seed or mnemonic of the account that
+	// pays for fee-bump transactions. Fee bumps are disabled when empty.
+	FeeAccountSecret string
+}
+
+// option describes a single configuration value and the names it goes by in
+// config files, the environment and on the command line.
+type option struct {
+	key   string
+	flag  string
+	def   string
+	usage string
+}
+
+var options = []option{
+	{"NETWORK", "network", "", "network to connect to: mainnet, testnet or a custom name (required)"},
+	{"NET_URL", "net-url", "", "Horizon server URL (required for custom networks)"},
+	{"NET_PASSPHRASE", "net-passphrase", "", "network passphrase (required for custom networks)"},
+	{"APP_PORT", "port", ":8080", "address the HTTP server listens on"},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "30s", "how long shutdown waits for in-flight work"},
+	{"TLS_CERT_FILE", "tls-cert", "", "TLS certificate file, enables HTTPS together with TLS_KEY_FILE"},
+	{"TLS_KEY_FILE", "tls-key", "", "TLS private key file"},
+	{"TRUSTED_PROXIES", "trusted-proxies", "", "comma separated IPs or CIDRs of trusted reverse proxies"},
+	{"SESSION_TTL", "session-ttl", "30m", "how long a login session stays valid"},
+	{"ADMIN_TOKEN", "admin-token", "", "bearer token for operator endpoints, disabled when empty"},
+	{"WEBHOOK_SECRET", "webhook-secret", "", "secret used to sign webhook deliveries"},
+	{"DATA_DIR", "data-dir", "data", "directory for persisted state"},
+	{"KEYSTORE_PASSPHRASE", "keystore-passphrase", "", "passphrase encrypting keys held for recurring payments, disabled when empty"},
+	{"TX_CONFIRM_TIMEOUT", "tx-confirm-timeout", "30s", "how long to wait for a submitted transaction to be applied"},
+	{"TX_TIMEOUT", "tx-timeout", "60s", "how long a transaction stays valid after it is built"},
+	{"TX_LEDGER_WINDOW", "tx-ledger-window", "0", "number of ledgers a transaction stays valid for, 0 for no ledger bounds"},
+	{"TX_FEE_PROFILE", "tx-fee-profile", "normal", "default fee profile: low, normal or high"},
+	{"TX_MAX_FEE", "tx-max-fee", "15000000", "maximum fee of a single transaction in stroops"},
+	{"TX_MAX_FEE_PER_DAY", "tx-max-fee-per-day", "1000000000", "maximum fees spent per UTC day in stroops"},
+	{"FEE_ACCOUNT_SECRET", "fee-account-secret", "", "secret seed or mnemonic of the account paying for fee bumps"},
+}
+
+// presets holds the defaults for the networks we know about. Custom networks
+// must provide NET_URL and NET_PASSPHRASE themselves.
+var presets = map[string]map[string]string{
+	"mainnet": {
+		"NET_URL":        "https://api.mainnet.minepi.com",
+		"NET_PASSPHRASE": "Pi Network",
+	},
+	"testnet": {
+		"NET_URL":        "https://api.testnet.minepi.com",
+		"NET_PASSPHRASE": "Pi Testnet",
+	},
+}
+
+// Load builds the configuration from, in increasing order of precedence:
+// built-in defaults, the config file (.env unless -config is given), the
+// network specific file (.env.<network>), environment variables and
+// command line flags.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("pi", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a config file (default .env)")
+	flagValues := make(map[string]*string, len(options))
+	for _, opt := range options {
+		flagValues[opt.key] = fs.String(opt.flag, "", fmt.Sprintf("%s (env %s)", opt.usage, opt.key))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range options {
+			if opt.flag == f.Name {
+				flags[opt.key] = *flagValues[opt.key]
+			}
+		}
+	})
+
+	env := map[string]string{}
+	for _, opt := range options {
+		if v, ok := os.LookupEnv(opt.key); ok {
+			env[opt.key] = v
+		}
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path, required = os.Getenv("CONFIG_FILE"), true
+	}
+	if path == "" {
+		path, required = ".env", false
+	}
+
+	file, err := readFile(path, required)
+	if err != nil {
+		return nil, err
+	}
+
+	// There is no default network, funds must never move on mainnet just
+	// because the setting was forgotten. parse reports it missing.
+	network := firstSet("NETWORK", flags, env, file)
+	networkFile := map[string]string{}
+	if network != "" {
+		networkPath := filepath.Join(filepath.Dir(path), ".env."+network)
+		networkFile, err = readFile(networkPath, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values := map[string]string{}
+	for _, opt := range options {
+		values[opt.key] = opt.def
+	}
+	for _, layer := range []map[string]string{presets[network], file, networkFile, env, flags} {
+		for k, v := range layer {
+			values[k] = v
+		}
+	}
+	values["NETWORK"] = network
+
+	return parse(values)
+}
+
+// readFile reads a dotenv style file. Missing optional files yield no values.
+func readFile(path string, required bool) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func firstSet(key string, layers ...map[string]string) string {
+	for _, layer := range layers {
+		if v, ok := layer[key]; ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parse converts the merged raw values into a Config, collecting every
+// problem rather than stopping at the first one.
+func parse(values map[string]string) (*Config, error) {
+	p := &parser{values: values}
+
+	cfg := &Config{
+		Network: NetworkConfig{
+			Name:       p.required("NETWORK"),
+			HorizonURL: p.url("NET_URL"),
+			Passphrase: p.required("NET_PASSPHRASE"),
+		},
+		Server: ServerConfig{
+			Port:            p.address("APP_PORT"),
+			ShutdownTimeout: p.positiveDuration("SHUTDOWN_TIMEOUT"),
+			TLSCertFile:     p.file("TLS_CERT_FILE"),
+			TLSKeyFile:      p.file("TLS_KEY_FILE"),
+			TrustedProxies:  p.networks("TRUSTED_PROXIES"),
+			SessionTTL:      p.duration("SESSION_TTL"),
+			AdminToken:      p.string("ADMIN_TOKEN"),
+			WebhookSecret:   p.string("WEBHOOK_SECRET"),
+		},
+		Storage: StorageConfig{
+			DataDir:            p.required("DATA_DIR"),
+			KeystorePassphrase: p.string("KEYSTORE_PASSPHRASE"),
+		},
+		Tx: TxConfig{
+			ConfirmTimeout:   p.positiveDuration("TX_CONFIRM_TIMEOUT"),
+			Timeout:          p.duration("TX_TIMEOUT"),
+			LedgerWindow:     p.uint32("TX_LEDGER_WINDOW"),
+			FeeProfile:       p.oneOf("TX_FEE_PROFILE", "low", "normal", "high"),
+			MaxFee:           p.stroops("TX_MAX_FEE"),
+			MaxFeePerDay:     p.stroops("TX_MAX_FEE_PER_DAY"),
+			FeeAccountSecret: p.string("FEE_ACCOUNT_SECRET"),
+		},
+	}
+
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		p.fail("TLS_CERT_FILE", "must be set together with TLS_KEY_FILE")
+	}
+
+	if cfg.Server.SessionTTL < time.Minute {
+		p.fail("SESSION_TTL", "must be at least 1m")
+	}
+
+	if cfg.Tx.Timeout < time.Second {
+		p.fail("TX_TIMEOUT", "must be at least 1s, transactions are never left valid forever")
+	}
+
+	if cfg.Tx.MaxFeePerDay < cfg.Tx.MaxFee {
+		p.fail("TX_MAX_FEE_PER_DAY", "must be at least TX_MAX_FEE")
+	}
+
+	if len(p.errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(p.errs...))
+	}
+
+	return cfg, nil
+}
+
+type parser struct {
+	values map[string]string
+	errs   []error
+}
+
+func (p *parser) fail(key, format string, args ...any) {
+	p.errs = append(p.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) string(key string) string {
+	return strings.TrimSpace(p.values[key])
+}
+
+func (p *parser) required(key string) string {
+	v := p.string(key)
+	if v == "" {
+		p.fail(key, "must be set")
+	}
+	return v
+}
+
+func (p *parser) url(key string) string {
+	v := p.required(key)
+	if v == "" {
+		return ""
+	}
+
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.fail(key, "must be an absolute http(s) URL, got %q", v)
+		return ""
+	}
+	return strings.TrimSuffix(v, "/")
+}
+
+func (p *parser) address(key string) string {
+	v := p.required(key)
+	if v == "" {
+		return ""
+	}
+
+	// Accept a bare port number, as most hosting platforms provide one
+	addr := v
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if n, convErr := strconv.Atoi(port); err != nil || convErr != nil || n < 0 || n > 65535 {
+		p.fail(key, "must be a port or host:port address, got %q", v)
+		return ""
+	}
+	return addr
+}
+
+func (p *parser) duration(key string) time.Duration {
+	v := p.string(key)
+	if v == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		p.fail(key, "must be a non-negative duration such as 30s, got %q", v)
+		return 0
+	}
+	return d
+}
+
+// positiveDuration parses a duration for settings where 0 would disable
+// the wait altogether.
+func (p *parser) positiveDuration(key string) time.Duration {
+	v := p.string(key)
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		p.fail(key, "must be a positive duration such as 30s, got %q", v)
+		return 0
+	}
+	return d
+}
+
+func (p *parser) uint32(key string) uint32 {
+	v := p.string(key)
+	if v == "" {
+		return 0
+	}
+
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		p.fail(key, "must be a non-negative integer, got %q", v)
+		return 0
+	}
+	return uint32(n)
+}
+
+// stroops parses a positive amount of stroops.
+func (p *parser) stroops(key string) int64 {
+	v := p.required(key)
+	if v == "" {
+		return 0
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		p.fail(key, "must be a positive number of stroops, got %q", v)
+		return 0
+	}
+	return n
+}
+
+func (p *parser) oneOf(key string, allowed ...string) string {
+	v := p.string(key)
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+
+	p.fail(key, "must be one of %s, got %q", strings.Join(allowed, ", "), v)
+	return ""
+}
+
+func (p *parser) file(key string) string {
+	v := p.string(key)
+	if v == "" {
+		return ""
+	}
+
+	if _, err := os.Stat(v); err != nil {
+		p.fail(key, "cannot read %q: %v", v, err)
+		return ""
+	}
+	return v
+}
+
+func (p *parser) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(p.string(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// networks parses a list of IP addresses or CIDR ranges.
+func (p *parser) networks(key string) []string {
+	items := p.list(key)
+	for _, item := range items {
+		if net.ParseIP(item) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(item); err != nil {
+			p.fail(key, "%q is not an IP address or CIDR range", item)
+		}
+	}
+	return items
+}
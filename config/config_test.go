@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file and the network files next to it,
+// returning the config file's path.
+func writeConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "config")
+}
+
+// clearEnv keeps the environment of whoever runs the tests out of Load.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, opt := range options {
+		t.Setenv(opt.key, "")
+		os.Unsetenv(opt.key)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, map[string]string{
+		"config":        "NETWORK=testnet\nAPP_PORT=9000\nSESSION_TTL=1h\nTX_TIMEOUT=90s\n",
+		".env.testnet":  "SESSION_TTL=2h\nTX_MAX_FEE=5000\n",
+		".env.mainnet":  "TX_MAX_FEE=1\n",
+		".env.custom":   "NET_URL=https://horizon.example.com/\nNET_PASSPHRASE=Custom\n",
+		"custom-config": "NETWORK=custom\n",
+	})
+	t.Setenv("TX_TIMEOUT", "2m")
+
+	tests := []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "preset, file, network file and environment",
+			args: []string{"-config", path},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Network.Name != "testnet" || cfg.Network.Passphrase != "Pi Testnet" || cfg.Network.HorizonURL != "https://api.testnet.minepi.com" {
+					t.Errorf("network = %+v, want the testnet preset", cfg.Network)
+				}
+				if cfg.Server.Port != ":9000" {
+					t.Errorf("port = %q, want :9000 from the file", cfg.Server.Port)
+				}
+				if cfg.Server.SessionTTL != 2*time.Hour {
+					t.Errorf("session ttl = %s, want 2h from the network file", cfg.Server.SessionTTL)
+				}
+				if cfg.Tx.MaxFee != 5000 {
+					t.Errorf("max fee = %d, want 5000 from the network file", cfg.Tx.MaxFee)
+				}
+				if cfg.Tx.Timeout != 2*time.Minute {
+					t.Errorf("tx timeout = %s, want 2m from the environment", cfg.Tx.Timeout)
+				}
+				if cfg.Tx.ConfirmTimeout != 30*time.Second || cfg.Tx.FeeProfile != "normal" {
+					t.Errorf("defaults not applied: %+v", cfg.Tx)
+				}
+			},
+		},
+		{
+			name: "flags win",
+			args: []string{"-config", path, "-tx-timeout", "3m", "-port", "7000"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Tx.Timeout != 3*time.Minute || cfg.Server.Port != ":7000" {
+					t.Errorf("tx timeout = %s, port = %q, want the flags", cfg.Tx.Timeout, cfg.Server.Port)
+				}
+			},
+		},
+		{
+			name: "network flag picks the network file",
+			args: []string{"-config", path, "-network", "mainnet", "-tx-max-fee-per-day", "1"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Network.Passphrase != "Pi Network" || cfg.Tx.MaxFee != 1 {
+					t.Errorf("network = %+v, max fee = %d, want mainnet", cfg.Network, cfg.Tx.MaxFee)
+				}
+			},
+		},
+		{
+			name: "custom network",
+			args: []string{"-config", filepath.Join(filepath.Dir(path), "custom-config")},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Network.HorizonURL != "https://horizon.example.com" || cfg.Network.Passphrase != "Custom" {
+					t.Errorf("network = %+v, want the custom network", cfg.Network)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	clearEnv(t)
+	tests := []struct {
+		name   string
+		config string
+		// want lists the settings the error must report
+		want []string
+	}{
+		{"no network", "APP_PORT=8080\n", []string{"NETWORK: must be set"}},
+		{"custom network without url", "NETWORK=custom\n", []string{"NET_URL", "NET_PASSPHRASE"}},
+		{"bad url", "NETWORK=custom\nNET_URL=ftp://x\nNET_PASSPHRASE=x\n", []string{"NET_URL"}},
+		{"bad port", "NETWORK=testnet\nAPP_PORT=http\n", []string{"APP_PORT"}},
+		{"zero shutdown timeout", "NETWORK=testnet\nSHUTDOWN_TIMEOUT=0s\n", []string{"SHUTDOWN_TIMEOUT"}},
+		{"empty confirm timeout", "NETWORK=testnet\nTX_CONFIRM_TIMEOUT=\n", []string{"TX_CONFIRM_TIMEOUT"}},
+		{"negative duration", "NETWORK=testnet\nSESSION_TTL=-1m\n", []string{"SESSION_TTL"}},
+		{"short session", "NETWORK=testnet\nSESSION_TTL=30s\n", []string{"SESSION_TTL: must be at least 1m"}},
+		{"short tx timeout", "NETWORK=testnet\nTX_TIMEOUT=500ms\n", []string{"TX_TIMEOUT"}},
+		{"tls cert without key", "NETWORK=testnet\nTLS_CERT_FILE=config\n", []string{"TLS_CERT_FILE"}},
+		{"unknown fee profile", "NETWORK=testnet\nTX_FEE_PROFILE=urgent\n", []string{"TX_FEE_PROFILE"}},
+		{"daily cap below tx cap", "NETWORK=testnet\nTX_MAX_FEE=100\nTX_MAX_FEE_PER_DAY=10\n", []string{"TX_MAX_FEE_PER_DAY"}},
+		{"bad proxy", "NETWORK=testnet\nTRUSTED_PROXIES=10.0.0.0/8, proxy.local\n", []string{"TRUSTED_PROXIES"}},
+		{
+			name:   "every problem at once",
+			config: "NETWORK=testnet\nAPP_PORT=x\nTX_LEDGER_WINDOW=-1\nTX_MAX_FEE=0\n",
+			want:   []string{"APP_PORT", "TX_LEDGER_WINDOW", "TX_MAX_FEE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, map[string]string{"config": tt.config})
+			_, err := Load([]string{"-config", path})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMissingConfigFile(t *testing.T) {
+	if _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
@@ -1,45 +1,27 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"os"
+	"pi/config"
 	"pi/server"
 
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
 )
 
-func loadConfig() error {
-	err := godotenv.Load()
-	if err != nil {
-		return fmt.Errorf("error loading env config: %v", err)
-	}
-	color.Green("loaded base env config")
-
-	network := os.Getenv("NETWORK")
-	if network != "mainnet" && network != "testnet" {
-		return fmt.Errorf("invalid network")
-	}
-
-	rConfig := ".env." + network
-	err = godotenv.Overload(rConfig)
+func main() {
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		return fmt.Errorf("error loading network specific config")
+		log.Fatalf("%s: %v", "config", err)
 	}
-	color.Green("loaded %s config", network)
-
-	return nil
-}
+	color.Green("loaded %s config (%s)", cfg.Network.Name, cfg.Network.HorizonURL)
 
-func main() {
-	err := loadConfig()
+	srv, err := server.New(cfg)
 	if err != nil {
-		log.Fatalf("%s: %v", "config", err)
+		log.Fatalf("%s: %v", "server", err)
 	}
 
-	srv := server.New()
-	err = srv.Run(os.Getenv("APP_PORT"))
+	err = srv.Run()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pi_bot"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Currently open WebSocket connections.",
+	})
+
+	ClaimJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "claim_jobs_running",
+		Help:      "Claim bots currently running.",
+	})
+
+	HorizonRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "horizon_requests_total",
+		Help:      "Requests sent to Horizon, by endpoint and method.",
+	}, []string{"endpoint", "method"})
+
+	HorizonRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "horizon_request_duration_seconds",
+		Help:      "Horizon request latency, by endpoint and method.",
+		Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"endpoint", "method"})
+
+	HorizonErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "horizon_errors_total",
+		Help:      "Horizon requests that failed in transport or returned an error status.",
+	}, []string{"endpoint", "method"})
+
+	TransactionsSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_submitted_total",
+		Help:      "Transactions submitted to the network, by outcome and result code.",
+	}, []string{"outcome", "result_code"})
+
+	FeesSpent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fees_spent_stroops_total",
+		Help:      "Fees charged for submitted transactions, in stroops.",
+	})
+)
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records request count and latency for every route.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+
+		HTTPRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveSubmission records the outcome of a transaction submission and the
+// fee it was charged.
+func ObserveSubmission(successful bool, resultCode string, feeCharged int64) {
+	outcome := "failed"
+	if successful {
+		outcome = "success"
+	}
+	if resultCode == "" {
+		resultCode = "unknown"
+	}
+
+	TransactionsSubmitted.WithLabelValues(outcome, resultCode).Inc()
+	if feeCharged > 0 {
+		FeesSpent.Add(float64(feeCharged))
+	}
+}
@@ -0,0 +1,59 @@
+// Package qr renders QR codes as PNG or SVG images.
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Sizes are the bounds on the width of a rendered image, in pixels.
+const (
+	MinSize = 64
+	MaxSize = 1024
+)
+
+func encode(data string) (*qrcode.QRCode, error) {
+	code, err := qrcode.New(data, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding qr code: %w", err)
+	}
+	return code, nil
+}
+
+// PNG renders data as a size by size PNG image.
+func PNG(data string, size int) ([]byte, error) {
+	code, err := encode(data)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(size)
+}
+
+// SVG renders data as an SVG image size pixels wide. The modules are drawn
+// as a single path, so the image scales without blurring.
+func SVG(data string, size int) ([]byte, error) {
+	code, err := encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bitmap includes the quiet zone around the code
+	bitmap := code.Bitmap()
+	n := len(bitmap)
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, n, n, n, n, path.String())
+	return []byte(svg), nil
+}
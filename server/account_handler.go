@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Account shows the signers of the logged in wallet and the weight each
+// operation type needs.
+func (s *Server) Account(ctx *gin.Context) {
+	info, err := s.wallet.AccountInfo(ctx.Request.Context(), sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, info)
+}
+
+type SignersRequest struct {
+	wallet.SignerUpdate
+	FeeProfile string `json:"fee_profile"`
+}
+
+// UpdateSigners adds, removes or reweighs signers of the logged in wallet
+// and changes its thresholds.
+func (s *Server) UpdateSigners(ctx *gin.Context) {
+	var req SignersRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	kp := sessionKeypair(ctx)
+	res, err := s.wallet.UpdateSigners(ctx.Request.Context(), kp, req.SignerUpdate, profile)
+	switch {
+	case errors.Is(err, wallet.ErrLockout):
+		ctx.AbortWithStatusJSON(422, gin.H{
+			"message": err.Error(),
+			"code":    "lockout",
+		})
+		return
+	case errors.Is(err, wallet.ErrThresholdNotMet):
+		ctx.AbortWithStatusJSON(403, gin.H{
+			"message": err.Error(),
+			"code":    "threshold_not_met",
+		})
+		return
+	case err != nil:
+		s.txError(ctx, res, err)
+		return
+	}
+
+	info, _ := s.wallet.AccountInfo(ctx.Request.Context(), kp.Address())
+	ctx.JSON(200, gin.H{
+		"transaction": res,
+		"account":     info,
+	})
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireAdmin guards operator endpoints with the configured bearer token.
+// Without a token configured they are not available at all.
+func (s *Server) requireAdmin(ctx *gin.Context) {
+	token := s.cfg.Server.AdminToken
+	if token == "" {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": "operator endpoints are disabled",
+		})
+		return
+	}
+
+	given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		ctx.AbortWithStatusJSON(401, gin.H{
+			"message": "unauthorized",
+		})
+		return
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stellar/go/keypair"
+)
+
+// maxBatchUpload bounds the size of an uploaded payments CSV.
+const maxBatchUpload = 1 << 20
+
+// CreateBatch validates a CSV of payments from the logged in wallet and,
+// if every row is valid, starts sending them in the background. The CSV is
+// either the request body or a multipart "file" field.
+func (s *Server) CreateBatch(ctx *gin.Context) {
+	// Bounds multipart uploads too, FormFile reads the request body
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBatchUpload)
+	var body io.Reader = ctx.Request.Body
+	if ctx.ContentType() == "multipart/form-data" {
+		file, err := ctx.FormFile("file")
+		if tooLarge(err) || (err == nil && file.Size > maxBatchUpload) {
+			batchTooLarge(ctx)
+			return
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "a csv file is required",
+			})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "error reading upload",
+			})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	payments, err := wallet.ParseBatchCSV(body)
+	if tooLarge(err) {
+		batchTooLarge(ctx)
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(ctx.Query("fee_profile"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	kp := sessionKeypair(ctx)
+	batch, err := s.wallet.NewBatch(ctx.Request.Context(), kp, payments, profile)
+	if errors.Is(err, wallet.ErrBatchInvalid) {
+		ctx.AbortWithStatusJSON(422, gin.H{
+			"message": err.Error(),
+			"batch":   batch,
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	s.runBatch(kp, batch.ID)
+	ctx.JSON(202, batch)
+}
+
+// ResumeBatch sends the rows of an interrupted batch that aren't paid yet.
+func (s *Server) ResumeBatch(ctx *gin.Context) {
+	batch, ok := s.ownBatch(ctx)
+	if !ok {
+		return
+	}
+	if batch.Status == wallet.BatchInvalid {
+		ctx.AbortWithStatusJSON(409, gin.H{
+			"message": fmt.Sprintf("batch is %s", batch.Status),
+		})
+		return
+	}
+	if s.wallet.BatchActive(batch.ID) {
+		ctx.AbortWithStatusJSON(409, gin.H{
+			"message": wallet.ErrBatchRunning.Error(),
+		})
+		return
+	}
+
+	s.runBatch(sessionKeypair(ctx), batch.ID)
+	ctx.JSON(202, batch)
+}
+
+// tooLarge reports whether reading the request body hit maxBatchUpload.
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+func batchTooLarge(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(413, gin.H{
+		"message": fmt.Sprintf("csv is larger than %d bytes", maxBatchUpload),
+	})
+}
+
+// runBatch sends a batch in the background. Shutting down interrupts it
+// between transactions, it can be resumed afterwards.
+func (s *Server) runBatch(kp *keypair.Full, id string) {
+	go func() {
+		if _, err := s.wallet.RunBatch(s.ctx, kp, id); err != nil {
+			fmt.Printf("Batch %s: %v\n", id, err)
+		}
+	}()
+}
+
+func (s *Server) Batches(ctx *gin.Context) {
+	batches, err := s.wallet.Batches(sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, batches)
+}
+
+func (s *Server) Batch(ctx *gin.Context) {
+	batch, ok := s.ownBatch(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(200, batch)
+}
+
+// BatchReport downloads the status of every row of a batch as CSV.
+func (s *Server) BatchReport(ctx *gin.Context) {
+	batch, ok := s.ownBatch(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Header("Content-Type", "text/csv")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s.csv"`, batch.ID))
+	if err := batch.WriteReport(ctx.Writer); err != nil {
+		ctx.Error(err)
+	}
+}
+
+// ownBatch loads the batch in the URL, answering 404 for batches of other
+// wallets.
+func (s *Server) ownBatch(ctx *gin.Context) (*wallet.Batch, bool) {
+	batch, err := s.wallet.Batch(ctx.Param("id"))
+	if errors.Is(err, wallet.ErrBatchNotFound) || (err == nil && batch.Source != sessionKeypair(ctx).Address()) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrBatchNotFound.Error(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	return batch, true
+}
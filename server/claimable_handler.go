@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClaimantRequest struct {
+	Destination string `json:"destination" binding:"required"`
+	// Predicate defaults to unconditional
+	Predicate *wallet.Predicate `json:"predicate"`
+}
+
+type ClaimableRequest struct {
+	Amount     string            `json:"amount" binding:"required"`
+	Claimants  []ClaimantRequest `json:"claimants" binding:"required,min=1,dive"`
+	Memo       string            `json:"memo"`
+	MemoType   string            `json:"memo_type"`
+	FeeProfile string            `json:"fee_profile"`
+
+	// ReclaimAfter adds the sender as a claimant from this time on, so it
+	// can take back a balance nobody claimed
+	ReclaimAfter *time.Time `json:"reclaim_after"`
+}
+
+type ClaimableResponse struct {
+	*wallet.TxResult
+	BalanceID string `json:"balance_id"`
+}
+
+// CreateClaimable locks funds of the logged in wallet in a claimable
+// balance with arbitrary claimants and predicates.
+func (s *Server) CreateClaimable(ctx *gin.Context) {
+	var req ClaimableRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "amount and at least one claimant with a destination are required",
+		})
+		return
+	}
+
+	balance := wallet.NewClaimableBalance(req.Amount)
+	for _, c := range req.Claimants {
+		predicate := wallet.Unconditional()
+		if c.Predicate != nil {
+			predicate = *c.Predicate
+		}
+		balance.To(c.Destination, predicate)
+	}
+	if req.ReclaimAfter != nil {
+		balance.ReclaimableBy(sessionKeypair(ctx).Address(), *req.ReclaimAfter)
+	}
+	if _, err := balance.Operation(); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	memo, err := wallet.ParseMemo(req.MemoType, req.Memo)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	res, err := s.wallet.CreateClaimable(ctx.Request.Context(), sessionKeypair(ctx), memo, profile, balance)
+	if errors.Is(err, wallet.ErrTxPending) && res != nil && len(res.BalanceIDs) > 0 {
+		// The ID is already known, the balance exists once it is confirmed
+		ctx.JSON(202, gin.H{
+			"message":     err.Error(),
+			"code":        "pending",
+			"transaction": res.TxResult,
+			"balance_id":  res.BalanceIDs[0],
+		})
+		return
+	}
+	if err != nil {
+		var tx *wallet.TxResult
+		if res != nil {
+			tx = res.TxResult
+		}
+		s.txError(ctx, tx, err)
+		return
+	}
+
+	ctx.JSON(200, ClaimableResponse{
+		TxResult:  res.TxResult,
+		BalanceID: res.BalanceIDs[0],
+	})
+}
+
+// ReclaimableBalances lists balances the logged in wallet created and can
+// claim back.
+func (s *Server) ReclaimableBalances(ctx *gin.Context) {
+	balances, err := s.wallet.ReclaimableBalances(ctx.Request.Context(), sessionKeypair(ctx))
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, balances)
+}
+
+type ReclaimRequest struct {
+	// BalanceIDs defaults to every balance reclaimable now
+	BalanceIDs []string `json:"balance_ids"`
+	FeeProfile string   `json:"fee_profile"`
+}
+
+func (s *Server) Reclaim(ctx *gin.Context) {
+	var req ReclaimRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "invalid request",
+			})
+			return
+		}
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	results, err := s.wallet.Reclaim(ctx.Request.Context(), sessionKeypair(ctx), req.BalanceIDs, profile)
+	if errors.Is(err, wallet.ErrNothingToReclaim) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message":      err.Error(),
+			"transactions": results,
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"transactions": results,
+	})
+}
+
+// VerifyBalanceID checks that a claimable balance was created by the given
+// transaction.
+func (s *Server) VerifyBalanceID(ctx *gin.Context) {
+	if !s.ownTransaction(ctx) {
+		return
+	}
+
+	index, err := s.wallet.VerifyBalanceID(ctx.Request.Context(), ctx.Param("hash"), ctx.Param("id"))
+	if errors.Is(err, wallet.ErrBalanceIDMismatch) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"balance_id":      ctx.Param("id"),
+		"tx_hash":         ctx.Param("hash"),
+		"operation_index": index,
+	})
+}
@@ -2,38 +2,48 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"pi/util"
 	"pi/wallet"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
 )
 
 type ConcurrentBot struct {
+	id                string
 	wallet            *wallet.Wallet
 	conn              *websocket.Conn
 	mainKp            *keypair.Full
 	sponsorKp         *keypair.Full
 	withdrawalAddress string
+	memo              txnbuild.Memo
 	amount            string
 	lockedBalanceID   string
-	mutex             sync.RWMutex
 	ctx               context.Context
 	cancel            context.CancelFunc
 }
 
-func NewConcurrentBot(w *wallet.Wallet, conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, withdrawalAddr, amount, lockedBalanceID string) *ConcurrentBot {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewConcurrentBot(parent context.Context, w *wallet.Wallet, conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, withdrawalAddr string, memo txnbuild.Memo, amount, lockedBalanceID string) *ConcurrentBot {
+	ctx, cancel := context.WithCancel(parent)
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
 	return &ConcurrentBot{
+		id:                hex.EncodeToString(id),
 		wallet:            w,
 		conn:              conn,
 		mainKp:            mainKp,
 		sponsorKp:         sponsorKp,
 		withdrawalAddress: withdrawalAddr,
+		memo:              memo,
 		amount:            amount,
 		lockedBalanceID:   lockedBalanceID,
 		ctx:               ctx,
@@ -76,20 +86,28 @@ func (cb *ConcurrentBot) aggressiveClaim(goroutineID int, balance *horizon.Claim
 		default:
 		}
 
-		var hash string
+		var res *wallet.TxResult
 		var amount float64
 		var err error
 
+		// Each goroutine retries one logical claim, so a submission that
+		// times out is resolved before anything new is built
+		ctx := wallet.WithIdempotencyKey(cb.ctx, fmt.Sprintf("claim-%s-%d", cb.id, goroutineID))
+
 		// Use sponsor if available, otherwise use main wallet
 		if cb.sponsorKp != nil {
-			// Use high competitive fees with sponsor
-			fee := util.GetCompetitiveFee()
-			hash, amount, err = cb.wallet.ClaimBalanceWithSponsor(cb.mainKp, cb.sponsorKp, cb.lockedBalanceID, fee)
+			// Outbid other senders with sponsor, within the operator's fee cap
+			res, err = cb.wallet.ClaimBalanceWithSponsor(ctx, cb.mainKp, cb.sponsorKp, cb.lockedBalanceID, wallet.FeeHigh)
+			amount, _ = strconv.ParseFloat(balance.Amount, 64)
 		} else {
 			// Use main wallet with competitive fee
-			hash, amount, err = cb.wallet.WithdrawClaimableBalance(cb.mainKp, cb.amount, cb.lockedBalanceID, cb.withdrawalAddress)
+			res, amount, err = cb.wallet.WithdrawClaimableBalance(ctx, cb.mainKp, cb.amount, cb.lockedBalanceID, cb.withdrawalAddress, cb.memo)
+		}
+
+		var hash string
+		if res != nil {
+			hash = res.Hash
 		}
-		
 		cb.sendAttemptLog(goroutineID, attempt, hash, amount, err)
 		
 		if err == nil {
@@ -107,6 +125,9 @@ func (cb *ConcurrentBot) aggressiveClaim(goroutineID int, balance *horizon.Claim
 // New function for continuous transfer monitoring starting at unlock time
 func (cb *ConcurrentBot) continuousTransferMonitor() {
 	cb.sendMessage("💰 Starting continuous transfer monitor (every 10ms)")
+
+	// Never send a second transfer while the first may still be applied
+	ctx := wallet.WithIdempotencyKey(cb.ctx, "transfer-"+cb.id)
 	
 	// Run indefinitely every 10ms until successful transfer
 	for {
@@ -117,14 +138,13 @@ func (cb *ConcurrentBot) continuousTransferMonitor() {
 		}
 
 		// Always attempt transfer regardless of balance
-		availableBalance, err := cb.wallet.GetAvailableBalance(cb.mainKp)
+		availableBalance, err := cb.wallet.GetAvailableBalance(cb.ctx, cb.mainKp)
 		if err == nil && availableBalance != "0" && availableBalance != "0.00" {
 			// Attempt transfer with high fee
-			transferFee := util.GetTransferFee()
-			hash, err := cb.wallet.TransferWithFee(cb.mainKp, availableBalance, cb.withdrawalAddress, transferFee)
+			res, err := cb.wallet.TransferWithFee(ctx, cb.mainKp, availableBalance, cb.withdrawalAddress, cb.memo, wallet.FeeHigh)
 			
 			if err == nil {
-				cb.sendSuccess(fmt.Sprintf("Transfer completed: %s PI - Hash: %s", availableBalance, hash))
+				cb.sendSuccess(fmt.Sprintf("Transfer completed: %s PI - Hash: %s", availableBalance, res.Hash))
 				return
 			} else {
 				cb.sendMessage(fmt.Sprintf("Transfer retry: %s", err.Error()))
@@ -143,9 +163,9 @@ func (cb *ConcurrentBot) sendMessage(msg string) {
 		Success: true,
 		Action:  "info",
 	}
-	cb.mutex.Lock()
+	writeMu.Lock()
 	cb.conn.WriteJSON(response)
-	cb.mutex.Unlock()
+	writeMu.Unlock()
 }
 
 func (cb *ConcurrentBot) sendError(msg string) {
@@ -155,9 +175,9 @@ func (cb *ConcurrentBot) sendError(msg string) {
 		Success: false,
 		Action:  "error",
 	}
-	cb.mutex.Lock()
+	writeMu.Lock()
 	cb.conn.WriteJSON(response)
-	cb.mutex.Unlock()
+	writeMu.Unlock()
 }
 
 func (cb *ConcurrentBot) sendSuccess(msg string) {
@@ -167,9 +187,9 @@ func (cb *ConcurrentBot) sendSuccess(msg string) {
 		Success: true,
 		Action:  "success",
 	}
-	cb.mutex.Lock()
+	writeMu.Lock()
 	cb.conn.WriteJSON(response)
-	cb.mutex.Unlock()
+	writeMu.Unlock()
 }
 
 func (cb *ConcurrentBot) sendAttemptLog(goroutineID, attempt int, hash string, amount float64, err error) {
@@ -188,7 +208,7 @@ func (cb *ConcurrentBot) sendAttemptLog(goroutineID, attempt int, hash string, a
 		Action:        "attempt",
 	}
 	
-	cb.mutex.Lock()
+	writeMu.Lock()
 	cb.conn.WriteJSON(response)
-	cb.mutex.Unlock()
+	writeMu.Unlock()
 }
\ No newline at end of file
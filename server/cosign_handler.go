@@ -0,0 +1,258 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CosignPayment struct {
+	Destination string `json:"destination" binding:"required"`
+	Amount      string `json:"amount" binding:"required"`
+	Memo        string `json:"memo"`
+	MemoType    string `json:"memo_type"`
+}
+
+// CosignRequest proposes either a payment or a signer update for a multisig
+// account. Account defaults to the logged in wallet.
+type CosignRequest struct {
+	Account string `json:"account"`
+	// ExpiresIn is a Go duration or whole days such as "7d"
+	ExpiresIn  string               `json:"expires_in"`
+	FeeProfile string               `json:"fee_profile"`
+	Payment    *CosignPayment       `json:"payment"`
+	Signers    *wallet.SignerUpdate `json:"signers"`
+}
+
+type CosignSignatureRequest struct {
+	// Signer and Signature are a base64 signature of the transaction hash
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+	// EnvelopeXDR is a signed copy of the transaction, instead
+	EnvelopeXDR string `json:"envelope_xdr"`
+}
+
+type CosignResponse struct {
+	*wallet.PendingTx
+	NetworkPassphrase string `json:"network_passphrase"`
+	// UnsignedXDR is what signers sign with their own wallet
+	UnsignedXDR string `json:"unsigned_xdr"`
+	Remaining   int32  `json:"remaining_weight"`
+}
+
+// CreateCosign proposes a transaction for a multisig account the logged in
+// wallet signs for, signed by it, to collect the other signatures.
+func (s *Server) CreateCosign(ctx *gin.Context) {
+	var req CosignRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Payment == nil) == (req.Signers == nil) {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "either payment or signers is required",
+		})
+		return
+	}
+
+	window := wallet.DefaultCosignWindow
+	if req.ExpiresIn != "" {
+		expiresIn, err := parseInterval(req.ExpiresIn)
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "invalid expires_in: " + err.Error(),
+			})
+			return
+		}
+		window = expiresIn
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	kp := sessionKeypair(ctx)
+	account := req.Account
+	if account == "" {
+		account = kp.Address()
+	}
+
+	var p *wallet.PendingTx
+	if req.Payment != nil {
+		memo, err := wallet.ParseMemo(req.Payment.MemoType, req.Payment.Memo)
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		p, err = s.wallet.ProposePayment(ctx.Request.Context(), kp, account, req.Payment.Destination, req.Payment.Amount, memo, profile, window)
+	} else {
+		p, err = s.wallet.ProposeSignerUpdate(ctx.Request.Context(), kp, account, *req.Signers, profile, window)
+	}
+	if err != nil {
+		s.cosignError(ctx, p, err)
+		return
+	}
+
+	s.cosignResponse(ctx, p)
+}
+
+func (s *Server) PendingTxs(ctx *gin.Context) {
+	pending, err := s.wallet.PendingTxs(sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, pending)
+}
+
+func (s *Server) PendingTx(ctx *gin.Context) {
+	p, ok := s.visiblePendingTx(ctx)
+	if !ok {
+		return
+	}
+
+	s.cosignResponse(ctx, p)
+}
+
+// SignPendingTx signs a proposal with the logged in wallet's key.
+func (s *Server) SignPendingTx(ctx *gin.Context) {
+	if _, ok := s.visiblePendingTx(ctx); !ok {
+		return
+	}
+
+	p, err := s.wallet.SignPending(ctx.Request.Context(), sessionKeypair(ctx), ctx.Param("id"))
+	if err != nil {
+		s.cosignError(ctx, p, err)
+		return
+	}
+
+	s.cosignResponse(ctx, p)
+}
+
+// AddPendingSignature adds a signature made by a signer's own wallet, so
+// their secret key never reaches the server.
+func (s *Server) AddPendingSignature(ctx *gin.Context) {
+	var req CosignSignatureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.EnvelopeXDR == "" && (req.Signer == "" || req.Signature == "")) {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "signer and signature, or envelope_xdr, are required",
+		})
+		return
+	}
+	if _, ok := s.visiblePendingTx(ctx); !ok {
+		return
+	}
+
+	var p *wallet.PendingTx
+	var err error
+	if req.EnvelopeXDR != "" {
+		p, err = s.wallet.AddEnvelope(ctx.Request.Context(), ctx.Param("id"), req.EnvelopeXDR)
+	} else {
+		p, err = s.wallet.AddSignature(ctx.Request.Context(), ctx.Param("id"), req.Signer, req.Signature)
+	}
+	if err != nil {
+		s.cosignError(ctx, p, err)
+		return
+	}
+
+	s.cosignResponse(ctx, p)
+}
+
+// DiscardPendingTx drops a proposal, only its proposer or the account
+// itself can.
+func (s *Server) DiscardPendingTx(ctx *gin.Context) {
+	p, ok := s.visiblePendingTx(ctx)
+	if !ok {
+		return
+	}
+	if address := sessionKeypair(ctx).Address(); p.Proposer != address && p.Source != address {
+		ctx.AbortWithStatusJSON(403, gin.H{
+			"message": "only the proposer or the account can discard the transaction",
+		})
+		return
+	}
+
+	p, err := s.wallet.DiscardPending(ctx.Param("id"))
+	if err != nil {
+		s.cosignError(ctx, p, err)
+		return
+	}
+
+	s.cosignResponse(ctx, p)
+}
+
+func (s *Server) cosignResponse(ctx *gin.Context, p *wallet.PendingTx) {
+	unsigned, err := p.UnsignedXDR()
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, CosignResponse{
+		PendingTx:         p,
+		NetworkPassphrase: s.cfg.Network.Passphrase,
+		UnsignedXDR:       unsigned,
+		Remaining:         max(p.Required-p.Weight, 0),
+	})
+}
+
+// visiblePendingTx checks the proposal in the URL is for the logged in
+// wallet or one it signs for, answering 404 otherwise.
+func (s *Server) visiblePendingTx(ctx *gin.Context) (*wallet.PendingTx, bool) {
+	address := sessionKeypair(ctx).Address()
+	p, err := s.wallet.PendingTx(ctx.Param("id"))
+	if errors.Is(err, wallet.ErrPendingNotFound) || (err == nil && p.Source != address && !p.CanSign(address)) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrPendingNotFound.Error(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	return p, true
+}
+
+func (s *Server) cosignError(ctx *gin.Context, p *wallet.PendingTx, err error) {
+	// The threshold was met and the transaction sent, but it didn't go
+	// through (yet)
+	if p != nil && p.Status == wallet.CosignSubmitted {
+		s.txError(ctx, p.Result, err)
+		return
+	}
+
+	status, code := 400, ""
+	switch {
+	case errors.Is(err, wallet.ErrPendingNotFound):
+		status = 404
+	case errors.Is(err, wallet.ErrUnAuthorized):
+		status = 403
+	case errors.Is(err, wallet.ErrNotCollecting):
+		status = 409
+	case errors.Is(err, wallet.ErrLockout):
+		status, code = 422, "lockout"
+	case errors.Is(err, wallet.ErrMemoRequired):
+		status, code = 422, "memo_required"
+	case errors.Is(err, wallet.ErrDestinationNotFunded):
+		status, code = 422, "destination_not_funded"
+	}
+
+	body := gin.H{
+		"message": err.Error(),
+	}
+	if code != "" {
+		body["code"] = code
+	}
+	ctx.AbortWithStatusJSON(status, body)
+}
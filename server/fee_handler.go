@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fees reports the current fee of each fee profile and the remaining fee
+// budget for today.
+func (s *Server) Fees(ctx *gin.Context) {
+	estimate, err := s.wallet.EstimateFees(ctx.Request.Context())
+	if err != nil {
+		ctx.AbortWithStatusJSON(502, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, estimate)
+}
+
+type FeeBumpRequest struct {
+	FeeProfile string `json:"fee_profile"`
+}
+
+// FeeBump rescues a pending transaction by wrapping it in a fee-bump
+// transaction paid by the fee account.
+func (s *Server) FeeBump(ctx *gin.Context) {
+	var req FeeBumpRequest
+	// The body is optional, the default profile is high
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "invalid request",
+			})
+			return
+		}
+	}
+	if req.FeeProfile == "" {
+		req.FeeProfile = string(wallet.FeeHigh)
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	res, err := s.wallet.FeeBump(ctx.Request.Context(), ctx.Param("hash"), profile)
+	switch {
+	case errors.Is(err, wallet.ErrTxNotFound):
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, wallet.ErrFeeBumpDisabled):
+		ctx.AbortWithStatusJSON(503, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, wallet.ErrTxPending):
+		// Submitted, but not confirmed yet
+		ctx.JSON(202, gin.H{
+			"message":     err.Error(),
+			"transaction": res,
+		})
+		return
+	case err != nil:
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message":     err.Error(),
+			"transaction": res,
+		})
+		return
+	}
+
+	ctx.JSON(200, res)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is up and serving requests.
+func (s *Server) Healthz(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the server can do useful work, i.e. whether its
+// Horizon dependency is reachable and configured correctly.
+func (s *Server) Readyz(ctx *gin.Context) {
+	if s.draining.Load() {
+		ctx.AbortWithStatusJSON(503, gin.H{
+			"status":  "draining",
+			"message": "server is shutting down",
+		})
+		return
+	}
+
+	if err := s.wallet.CheckReadiness(ctx.Request.Context()); err != nil {
+		ctx.AbortWithStatusJSON(503, gin.H{
+			"status":  "unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"status": "ready"})
+}
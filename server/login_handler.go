@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stellar/go/keypair"
@@ -20,6 +21,9 @@ type LoginResponse struct {
 	LockedBalances   []horizon.ClaimableBalance `json:"locked_balances"`  // Fixed typo
 	WalletAddress    string                     `json:"wallet_address"`
 	SeedPhrase       string                     `json:"seed_phrase"`
+	WithdrawURL      string                     `json:"withdraw_url"`
+	SessionToken     string                     `json:"session_token"`
+	SessionExpiresAt time.Time                  `json:"session_expires_at"`
 }
 
 func (s *Server) getWalletData(ctx *gin.Context, seedPhrase string, kp *keypair.Full) {
@@ -29,9 +33,9 @@ func (s *Server) getWalletData(ctx *gin.Context, seedPhrase string, kp *keypair.
 		lockedBalances   []horizon.ClaimableBalance
 	)
 
-	g, _ := errgroup.WithContext(ctx)
+	g, gctx := errgroup.WithContext(ctx.Request.Context())
 	g.Go(func() error {
-		balance, err := s.wallet.GetAvailableBalance(kp)
+		balance, err := s.wallet.GetAvailableBalance(gctx, kp)
 		if err != nil {
 			return err
 		}
@@ -41,7 +45,7 @@ func (s *Server) getWalletData(ctx *gin.Context, seedPhrase string, kp *keypair.
 	})
 
 	g.Go(func() error {
-		txns, err := s.wallet.GetTransactions(kp, 5)
+		txns, err := s.wallet.GetTransactions(gctx, kp, 5)
 		if err != nil {
 			return err
 		}
@@ -50,7 +54,7 @@ func (s *Server) getWalletData(ctx *gin.Context, seedPhrase string, kp *keypair.
 	})
 
 	g.Go(func() error {
-		lb, err := s.wallet.GetLockedBalances(kp)
+		lb, err := s.wallet.GetLockedBalances(gctx, kp)
 		if err != nil {
 			return err
 		}
@@ -65,12 +69,17 @@ func (s *Server) getWalletData(ctx *gin.Context, seedPhrase string, kp *keypair.
 		return
 	}
 
+	token, expires := s.startSession(ctx, kp)
+
 	ctx.JSON(200, LoginResponse{
 		AvailableBalance: availableBalance,
 		Transactions:     transactions,
 		LockedBalances:   lockedBalances,  // Fixed typo
 		WalletAddress:    s.wallet.GetAddress(kp),
 		SeedPhrase:       seedPhrase,
+		WithdrawURL:      s.websocketURL(ctx, "/ws/withdraw"),
+		SessionToken:     token,
+		SessionExpiresAt: expires,
 	})
 }
 
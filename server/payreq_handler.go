@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRequestExpiry applies when a payment request gives no expiry.
+const defaultRequestExpiry = 24 * time.Hour
+
+type PaymentRequestRequest struct {
+	Amount  string `json:"amount" binding:"required"`
+	Message string `json:"message"`
+	// ExpiresIn is a Go duration or whole days such as "7d", ExpiresAt
+	// takes precedence
+	ExpiresIn  string     `json:"expires_in"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	WebhookURL string     `json:"webhook_url"`
+}
+
+// CreatePaymentRequest asks for a payment to the logged in wallet.
+func (s *Server) CreatePaymentRequest(ctx *gin.Context) {
+	var req PaymentRequestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "amount is required",
+		})
+		return
+	}
+
+	expiresAt := time.Now().Add(defaultRequestExpiry)
+	if req.ExpiresIn != "" {
+		expiresIn, err := parseInterval(req.ExpiresIn)
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, gin.H{
+				"message": "invalid expires_in: " + err.Error(),
+			})
+			return
+		}
+		expiresAt = time.Now().Add(expiresIn)
+	}
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+
+	payReq, err := s.wallet.CreatePaymentRequest(ctx.Request.Context(), sessionKeypair(ctx).Address(), wallet.PaymentRequestPlan{
+		Amount:     req.Amount,
+		Message:    req.Message,
+		ExpiresAt:  expiresAt,
+		WebhookURL: req.WebhookURL,
+	})
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, payReq)
+}
+
+func (s *Server) PaymentRequests(ctx *gin.Context) {
+	requests, err := s.wallet.PaymentRequests(sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, requests)
+}
+
+func (s *Server) PaymentRequest(ctx *gin.Context) {
+	payReq, err := s.wallet.PaymentRequest(ctx.Param("id"))
+	if errors.Is(err, wallet.ErrRequestNotFound) || (err == nil && payReq.Destination != sessionKeypair(ctx).Address()) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrRequestNotFound.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, payReq)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTrustedProxies converts the configured proxy IPs and CIDRs into
+// networks. The config package has already validated them.
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			nets = append(nets, n)
+		}
+	}
+
+	return nets
+}
+
+// fromTrustedProxy reports whether the request was sent directly by one of
+// the configured reverse proxies, i.e. whether its X-Forwarded-* headers can
+// be believed.
+func (s *Server) fromTrustedProxy(ctx *gin.Context) bool {
+	ip := net.ParseIP(ctx.RemoteIP())
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range s.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestScheme returns the scheme the client used to reach us, looking
+// through a TLS terminating proxy if it is trusted.
+func (s *Server) requestScheme(ctx *gin.Context) string {
+	if ctx.Request.TLS != nil {
+		return "https"
+	}
+
+	if s.fromTrustedProxy(ctx) {
+		proto := ctx.GetHeader("X-Forwarded-Proto")
+		if i := strings.Index(proto, ","); i >= 0 {
+			proto = proto[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(proto), "https") {
+			return "https"
+		}
+	}
+
+	return "http"
+}
+
+// requestHost returns the host the client addressed.
+func (s *Server) requestHost(ctx *gin.Context) string {
+	if s.fromTrustedProxy(ctx) {
+		if host := ctx.GetHeader("X-Forwarded-Host"); host != "" {
+			return strings.TrimSpace(strings.Split(host, ",")[0])
+		}
+	}
+
+	return ctx.Request.Host
+}
+
+// websocketURL builds the URL clients should use to reach the given socket
+// path, using wss:// whenever the page itself was served over HTTPS.
+func (s *Server) websocketURL(ctx *gin.Context, path string) string {
+	scheme := "ws"
+	if s.requestScheme(ctx) == "https" {
+		scheme = "wss"
+	}
+
+	return scheme + "://" + s.requestHost(ctx) + path
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"pi/qr"
+	"pi/wallet"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QR renders a QR code for an address, a muxed address or a SEP-7 payment
+// URI, given as data. format is png (the default) or svg, size the width
+// in pixels.
+func (s *Server) QR(ctx *gin.Context) {
+	data := ctx.Query("data")
+	var err error
+	if strings.HasPrefix(data, "web+stellar:") {
+		err = wallet.ValidatePayURI(data)
+	} else {
+		err = wallet.ValidateAddress(data)
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	size, err := strconv.Atoi(ctx.DefaultQuery("size", "256"))
+	if err != nil || size < qr.MinSize || size > qr.MaxSize {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "size must be between " + strconv.Itoa(qr.MinSize) + " and " + strconv.Itoa(qr.MaxSize),
+		})
+		return
+	}
+
+	var image []byte
+	var contentType string
+	switch ctx.DefaultQuery("format", "png") {
+	case "png":
+		image, err = qr.PNG(data, size)
+		contentType = "image/png"
+	case "svg":
+		image, err = qr.SVG(data, size)
+		contentType = "image/svg+xml"
+	default:
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "format must be png or svg",
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// The image only depends on the query
+	ctx.Header("Cache-Control", "public, max-age=86400")
+	ctx.Data(200, contentType, image)
+}
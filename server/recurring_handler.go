@@ -0,0 +1,207 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewRuns is how many upcoming runs are shown by default.
+const previewRuns = 5
+
+type RecurringRequest struct {
+	Destination string `json:"destination" binding:"required"`
+	Amount      string `json:"amount" binding:"required"`
+	Memo        string `json:"memo"`
+	MemoType    string `json:"memo_type"`
+	// Schedule is hourly, daily, weekly, monthly or a cron expression
+	Schedule   string `json:"schedule" binding:"required"`
+	Cap        string `json:"cap" binding:"required"`
+	WebhookURL string `json:"webhook_url"`
+	FeeProfile string `json:"fee_profile"`
+}
+
+type RecurringResponse struct {
+	*wallet.RecurringPayment
+	UpcomingRuns any `json:"upcoming_runs"`
+}
+
+// CreateRecurring schedules a payment from the logged in wallet.
+func (s *Server) CreateRecurring(ctx *gin.Context) {
+	var req RecurringRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "destination, amount, schedule and cap are required",
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	payment, err := s.wallet.CreateRecurring(ctx.Request.Context(), sessionKeypair(ctx), wallet.RecurringPlan{
+		Destination: req.Destination,
+		Amount:      req.Amount,
+		MemoType:    req.MemoType,
+		Memo:        req.Memo,
+		Schedule:    req.Schedule,
+		Cap:         req.Cap,
+		WebhookURL:  req.WebhookURL,
+		FeeProfile:  profile,
+	})
+	if err != nil {
+		s.recurringError(ctx, err)
+		return
+	}
+
+	s.recurringResponse(ctx, payment)
+}
+
+func (s *Server) RecurringPayments(ctx *gin.Context) {
+	payments, err := s.wallet.RecurringPayments(sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, payments)
+}
+
+func (s *Server) RecurringPayment(ctx *gin.Context) {
+	payment, ok := s.ownRecurring(ctx)
+	if !ok {
+		return
+	}
+
+	s.recurringResponse(ctx, payment)
+}
+
+// PreviewSchedule lists the next runs of a schedule, to check it before
+// creating a payment with it.
+func (s *Server) PreviewSchedule(ctx *gin.Context) {
+	count, err := strconv.Atoi(ctx.DefaultQuery("count", strconv.Itoa(previewRuns)))
+	if err != nil || count < 1 || count > 100 {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "count must be between 1 and 100",
+		})
+		return
+	}
+
+	runs, err := wallet.PreviewSchedule(ctx.Query("schedule"), count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"upcoming_runs": runs,
+	})
+}
+
+func (s *Server) PauseRecurring(ctx *gin.Context) {
+	if _, ok := s.ownRecurring(ctx); !ok {
+		return
+	}
+
+	payment, err := s.wallet.PauseRecurring(ctx.Param("id"))
+	if err != nil {
+		s.recurringError(ctx, err)
+		return
+	}
+
+	s.recurringResponse(ctx, payment)
+}
+
+func (s *Server) ResumeRecurring(ctx *gin.Context) {
+	if _, ok := s.ownRecurring(ctx); !ok {
+		return
+	}
+
+	payment, err := s.wallet.ResumeRecurring(ctx.Param("id"))
+	if err != nil {
+		s.recurringError(ctx, err)
+		return
+	}
+
+	s.recurringResponse(ctx, payment)
+}
+
+func (s *Server) DeleteRecurring(ctx *gin.Context) {
+	if _, ok := s.ownRecurring(ctx); !ok {
+		return
+	}
+
+	if err := s.wallet.DeleteRecurring(ctx.Param("id")); err != nil {
+		s.recurringError(ctx, err)
+		return
+	}
+
+	ctx.Status(204)
+}
+
+func (s *Server) recurringResponse(ctx *gin.Context, payment *wallet.RecurringPayment) {
+	runs, err := payment.Preview(previewRuns)
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, RecurringResponse{
+		RecurringPayment: payment,
+		UpcomingRuns:     runs,
+	})
+}
+
+// ownRecurring checks the payment in the URL belongs to the logged in
+// wallet, answering 404 otherwise.
+func (s *Server) ownRecurring(ctx *gin.Context) (*wallet.RecurringPayment, bool) {
+	payment, err := s.wallet.RecurringPayment(ctx.Param("id"))
+	if errors.Is(err, wallet.ErrRecurringNotFound) || (err == nil && payment.Source != sessionKeypair(ctx).Address()) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrRecurringNotFound.Error(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	return payment, true
+}
+
+func (s *Server) recurringError(ctx *gin.Context, err error) {
+	status, code := 400, ""
+	switch {
+	case errors.Is(err, wallet.ErrKeystoreDisabled):
+		status = 503
+	case errors.Is(err, wallet.ErrRecurringNotFound):
+		status = 404
+	case errors.Is(err, wallet.ErrRecurringExhausted):
+		status = 409
+	case errors.Is(err, wallet.ErrMemoRequired):
+		status, code = 422, "memo_required"
+	}
+
+	body := gin.H{
+		"message": err.Error(),
+	}
+	if code != "" {
+		body["code"] = code
+	}
+	ctx.AbortWithStatusJSON(status, body)
+}
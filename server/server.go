@@ -1,29 +1,71 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"pi/config"
+	"pi/metrics"
 	"pi/wallet"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 type Server struct {
-	wallet *wallet.Wallet
+	cfg            *config.Config
+	wallet         *wallet.Wallet
+	trustedProxies []*net.IPNet
+	sessions       *sessionStore
+
+	// ctx is canceled when the server starts shutting down, stopping bots
+	// from launching new attempts.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	draining atomic.Bool
+
+	connsMu sync.Mutex
+	conns   map[*websocket.Conn]struct{}
 }
 
-func New() *Server {
-	return &Server{
-		wallet: wallet.New(),
+func New(cfg *config.Config) (*Server, error) {
+	w, err := wallet.New(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		cfg:            cfg,
+		wallet:         w,
+		trustedProxies: parseTrustedProxies(cfg.Server.TrustedProxies),
+		sessions:       newSessionStore(cfg.Server.SessionTTL),
+		ctx:            ctx,
+		cancel:         cancel,
+		conns:          make(map[*websocket.Conn]struct{}),
+	}, nil
 }
 
-func (s *Server) Run(port string) error {
+func (s *Server) Run() error {
+	port := s.cfg.Server.Port
+
 	gin.SetMode(gin.ReleaseMode)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(s.cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("error setting trusted proxies: %w", err)
+	}
+	r.Use(metrics.Middleware())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // or restrict to specific domains
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
@@ -33,14 +75,162 @@ func (s *Server) Run(port string) error {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/healthz", s.Healthz)
+	r.GET("/readyz", s.Readyz)
 	r.POST("/api/login", s.Login)
+	r.POST("/api/logout", s.Logout)
+	r.GET("/api/account", s.requireSession, s.Account)
+	r.POST("/api/account/signers", s.requireSession, s.UpdateSigners)
+	r.POST("/api/transfer", s.requireSession, s.Transfer)
+	r.POST("/api/claimable", s.requireSession, s.CreateClaimable)
+	r.GET("/api/claimable/reclaimable", s.requireSession, s.ReclaimableBalances)
+	r.POST("/api/claimable/reclaim", s.requireSession, s.Reclaim)
+	r.POST("/api/vesting", s.requireSession, s.CreateVesting)
+	r.GET("/api/vesting", s.requireSession, s.VestingSchedules)
+	r.GET("/api/vesting/:id", s.requireSession, s.VestingSchedule)
+	r.POST("/api/vesting/:id/resume", s.requireSession, s.ResumeVesting)
+	r.POST("/api/batch", s.requireSession, s.CreateBatch)
+	r.GET("/api/batch", s.requireSession, s.Batches)
+	r.GET("/api/batch/:id", s.requireSession, s.Batch)
+	r.GET("/api/batch/:id/report", s.requireSession, s.BatchReport)
+	r.POST("/api/batch/:id/resume", s.requireSession, s.ResumeBatch)
+	r.POST("/api/recurring", s.requireSession, s.CreateRecurring)
+	r.GET("/api/recurring", s.requireSession, s.RecurringPayments)
+	r.GET("/api/recurring/preview", s.requireSession, s.PreviewSchedule)
+	r.GET("/api/recurring/:id", s.requireSession, s.RecurringPayment)
+	r.POST("/api/recurring/:id/pause", s.requireSession, s.PauseRecurring)
+	r.POST("/api/recurring/:id/resume", s.requireSession, s.ResumeRecurring)
+	r.DELETE("/api/recurring/:id", s.requireSession, s.DeleteRecurring)
+	r.POST("/api/payment-requests", s.requireSession, s.CreatePaymentRequest)
+	r.GET("/api/payment-requests", s.requireSession, s.PaymentRequests)
+	r.GET("/api/payment-requests/:id", s.requireSession, s.PaymentRequest)
+	r.POST("/api/cosign", s.requireSession, s.CreateCosign)
+	r.GET("/api/cosign", s.requireSession, s.PendingTxs)
+	r.GET("/api/cosign/:id", s.requireSession, s.PendingTx)
+	r.POST("/api/cosign/:id/sign", s.requireSession, s.SignPendingTx)
+	r.POST("/api/cosign/:id/signatures", s.requireSession, s.AddPendingSignature)
+	r.DELETE("/api/cosign/:id", s.requireSession, s.DiscardPendingTx)
+	r.GET("/api/transactions/:hash", s.requireSession, s.TransactionStatus)
+	r.GET("/api/transactions/:hash/claimable/:id", s.requireSession, s.VerifyBalanceID)
+	r.GET("/api/fees", s.Fees)
+	r.GET("/api/qr", s.QR)
+	r.POST("/api/transactions/:hash/fee-bump", s.requireAdmin, s.FeeBump)
 	r.GET("/ws/withdraw", s.Withdraw)
 	r.GET("/", func(ctx *gin.Context) {
 		ctx.File("./public/index.html")
 	})
 	r.StaticFS("/assets", http.Dir("./public/assets"))
 
-	fmt.Printf("Advanced Pi Bot running on port: %s\n", port)
+	srv := &http.Server{
+		Addr:    port,
+		Handler: r,
+	}
+
+	if s.cfg.Server.TLSEnabled() {
+		certs, err := newCertReloader(s.cfg.Server.TLSCertFile, s.cfg.Server.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background work stops with the server context on shutdown
+	go s.wallet.RunRecurring(s.ctx)
+	go s.wallet.WatchPaymentRequests(s.ctx)
+	go s.wallet.SweepPendingTxs(s.ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// Certificates come from TLSConfig.GetCertificate
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- srv.ListenAndServe()
+	}()
+
+	if srv.TLSConfig != nil {
+		fmt.Printf("Advanced Pi Bot running on port: %s (TLS)\n", port)
+	} else {
+		fmt.Printf("Advanced Pi Bot running on port: %s\n", port)
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-sigCtx.Done():
+	}
+
+	return s.shutdown(srv)
+}
+
+// shutdown stops accepting work, tells WebSocket clients we are going away,
+// and waits for in-flight requests and submissions before returning.
+func (s *Server) shutdown(srv *http.Server) error {
+	fmt.Println("Shutting down, draining connections...")
+
+	s.draining.Store(true)
+	s.notifyConnections(WithdrawResponse{
+		Action:  "shutdown",
+		Message: "Server is shutting down, in-flight transactions will be completed",
+		Success: false,
+	})
+	s.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("error shutting down http server: %w", err))
+	}
+	if err := s.wallet.WaitForSubmissions(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	s.closeConnections()
 
-	return r.Run(port)
-}
\ No newline at end of file
+	fmt.Println("Shutdown complete")
+	return errors.Join(errs...)
+}
+
+func (s *Server) trackConnection(conn *websocket.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	s.conns[conn] = struct{}{}
+}
+
+func (s *Server) untrackConnection(conn *websocket.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *Server) notifyConnections(res WithdrawResponse) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	for conn := range s.conns {
+		s.sendResponse(conn, res)
+	}
+}
+
+func (s *Server) closeConnections() {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(time.Second)
+	for conn := range s.conns {
+		conn.WriteControl(websocket.CloseMessage, msg, deadline)
+		conn.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stellar/go/keypair"
+)
+
+const sessionCookie = "pi_session"
+
+// session is a logged in wallet. Sessions only live in memory, so a restart
+// logs everybody out and the keys never touch the disk.
+type session struct {
+	kp      *keypair.Full
+	expires time.Time
+}
+
+type sessionStore struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*session),
+	}
+}
+
+func (st *sessionStore) create(kp *keypair.Full) (string, time.Time) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(st.ttl)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Drop expired sessions while we're here
+	now := time.Now()
+	for t, sess := range st.sessions {
+		if now.After(sess.expires) {
+			delete(st.sessions, t)
+		}
+	}
+
+	st.sessions[token] = &session{kp: kp, expires: expires}
+	return token, expires
+}
+
+func (st *sessionStore) get(token string) (*keypair.Full, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[token]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(sess.expires) {
+		delete(st.sessions, token)
+		return nil, false
+	}
+	return sess.kp, true
+}
+
+func (st *sessionStore) delete(token string) {
+	st.mu.Lock()
+	delete(st.sessions, token)
+	st.mu.Unlock()
+}
+
+// sessionToken reads the session token from the Authorization header, or
+// the session cookie set at login.
+func sessionToken(ctx *gin.Context) string {
+	if token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer "); ok {
+		return token
+	}
+	token, _ := ctx.Cookie(sessionCookie)
+	return token
+}
+
+// startSession logs kp in and hands the client its session token, both in
+// the response and as a cookie for the browser UI.
+func (s *Server) startSession(ctx *gin.Context, kp *keypair.Full) (string, time.Time) {
+	token, expires := s.sessions.create(kp)
+
+	ctx.SetSameSite(http.SameSiteStrictMode)
+	ctx.SetCookie(sessionCookie, token, int(s.cfg.Server.SessionTTL.Seconds()), "/", "", s.requestScheme(ctx) == "https", true)
+	return token, expires
+}
+
+// requireSession rejects requests without a live session and makes the
+// session's keypair available to the handler.
+func (s *Server) requireSession(ctx *gin.Context) {
+	kp, ok := s.sessions.get(sessionToken(ctx))
+	if !ok {
+		ctx.AbortWithStatusJSON(401, gin.H{
+			"message": "not logged in or session expired",
+		})
+		return
+	}
+
+	ctx.Set("keypair", kp)
+	ctx.Next()
+}
+
+func sessionKeypair(ctx *gin.Context) *keypair.Full {
+	return ctx.MustGet("keypair").(*keypair.Full)
+}
+
+func (s *Server) Logout(ctx *gin.Context) {
+	s.sessions.delete(sessionToken(ctx))
+	ctx.SetCookie(sessionCookie, "", -1, "/", "", s.requestScheme(ctx) == "https", true)
+	ctx.Status(204)
+}
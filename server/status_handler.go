@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransactionStatus reports the tracked status of a transaction built by
+// the wallet.
+func (s *Server) TransactionStatus(ctx *gin.Context) {
+	if !s.ownTransaction(ctx) {
+		return
+	}
+
+	rec, err := s.wallet.TransactionStatus(ctx.Request.Context(), ctx.Param("hash"))
+	if errors.Is(err, wallet.ErrTxNotFound) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, rec)
+}
+
+// ownTransaction checks the transaction in the URL was sent from the
+// logged in wallet, answering 404 otherwise. It is checked before anything
+// asks Horizon about the transaction.
+func (s *Server) ownTransaction(ctx *gin.Context) bool {
+	rec, err := s.wallet.Transaction(ctx.Param("hash"))
+	if errors.Is(err, wallet.ErrTxNotFound) || (err == nil && rec.Source != sessionKeypair(ctx).Address()) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrTxNotFound.Error(),
+		})
+		return false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return false
+	}
+	return true
+}
@@ -2,9 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"pi/metrics"
 	"pi/util"
+	"pi/wallet"
 	"sync"
 	"time"
 
@@ -12,12 +15,15 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
 )
 
 type WithdrawRequest struct {
 	SeedPhrase        string `json:"seed_phrase"`
 	SponsorPhrase     string `json:"sponsor_phrase"`
 	WithdrawalAddress string `json:"withdrawal_address"`
+	Memo              string `json:"memo"`
+	MemoType          string `json:"memo_type"`
 	LockedBalanceID   string `json:"locked_balance_id"`
 	Amount            string `json:"amount"`
 }
@@ -50,6 +56,12 @@ func (s *Server) Withdraw(ctx *gin.Context) {
 	}
 	defer conn.Close()
 
+	metrics.WebSocketConnections.Inc()
+	defer metrics.WebSocketConnections.Dec()
+
+	s.trackConnection(conn)
+	defer s.untrackConnection(conn)
+
 	var req WithdrawRequest
 	_, message, err := conn.ReadMessage()
 	if err != nil {
@@ -63,6 +75,29 @@ func (s *Server) Withdraw(ctx *gin.Context) {
 		return
 	}
 
+	// Reject a bad destination or memo before any funds are touched
+	if err := wallet.ValidateAddress(req.WithdrawalAddress); err != nil {
+		s.sendErrorResponse(conn, "Invalid withdrawal address: "+err.Error())
+		return
+	}
+	memo, err := wallet.ParseMemo(req.MemoType, req.Memo)
+	if err != nil {
+		s.sendErrorResponse(conn, err.Error())
+		return
+	}
+	if err := s.wallet.CheckMemoRequired(ctx.Request.Context(), req.WithdrawalAddress, memo); err != nil {
+		action := "error"
+		if errors.Is(err, wallet.ErrMemoRequired) {
+			action = "memo_required"
+		}
+		s.sendResponse(conn, WithdrawResponse{
+			Action:  action,
+			Message: err.Error(),
+			Success: false,
+		})
+		return
+	}
+
 	// Get main keypair
 	mainKp, err := util.GetKeyFromSeed(req.SeedPhrase)
 	if err != nil {
@@ -89,11 +124,11 @@ func (s *Server) Withdraw(ctx *gin.Context) {
 	})
 
 	// Handle locked balance
-	s.handleLockedBalance(conn, mainKp, sponsorKp, req)
+	s.handleLockedBalance(conn, mainKp, sponsorKp, req, memo)
 }
 
-func (s *Server) handleLockedBalance(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest) {
-	balance, err := s.wallet.GetClaimableBalance(req.LockedBalanceID)
+func (s *Server) handleLockedBalance(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest, memo txnbuild.Memo) {
+	balance, err := s.wallet.GetClaimableBalance(s.ctx, req.LockedBalanceID)
 	if err != nil {
 		s.sendErrorResponse(conn, "Error getting locked balance: "+err.Error())
 		return
@@ -131,14 +166,14 @@ func (s *Server) handleLockedBalance(conn *websocket.Conn, mainKp, sponsorKp *ke
 
 	if time.Now().After(claimableAt) {
 		// Already unlocked, start aggressive claiming immediately
-		s.startAggressiveBot(conn, mainKp, sponsorKp, req, balance, claimableAt)
+		s.startAggressiveBot(conn, mainKp, sponsorKp, req, memo, balance, claimableAt)
 	} else {
 		// Schedule for exact unlock time
-		s.scheduleAggressiveBot(conn, mainKp, sponsorKp, req, balance, claimableAt)
+		s.scheduleAggressiveBot(conn, mainKp, sponsorKp, req, memo, balance, claimableAt)
 	}
 }
 
-func (s *Server) scheduleAggressiveBot(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest, balance *horizon.ClaimableBalance, claimableAt time.Time) {
+func (s *Server) scheduleAggressiveBot(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest, memo txnbuild.Memo, balance *horizon.ClaimableBalance, claimableAt time.Time) {
 	s.sendResponse(conn, WithdrawResponse{
 		Action:  "scheduled",
 		Message: fmt.Sprintf("Aggressive bot scheduled for exact unlock time: %s", claimableAt.Format("15:04:05")),
@@ -154,14 +189,21 @@ func (s *Server) scheduleAggressiveBot(conn *websocket.Conn, mainKp, sponsorKp *
 			Message: fmt.Sprintf("Waiting %.0f seconds until exact unlock time...", waitDuration.Seconds()),
 			Success: true,
 		})
-		time.Sleep(waitDuration)
+		select {
+		case <-time.After(waitDuration):
+		case <-s.ctx.Done():
+			return
+		}
 	}
 
-	s.startAggressiveBot(conn, mainKp, sponsorKp, req, balance, claimableAt)
+	s.startAggressiveBot(conn, mainKp, sponsorKp, req, memo, balance, claimableAt)
 }
 
-func (s *Server) startAggressiveBot(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest, balance *horizon.ClaimableBalance, claimableAt time.Time) {
-	bot := NewConcurrentBot(s.wallet, conn, mainKp, sponsorKp, req.WithdrawalAddress, req.Amount, req.LockedBalanceID)
+func (s *Server) startAggressiveBot(conn *websocket.Conn, mainKp, sponsorKp *keypair.Full, req WithdrawRequest, memo txnbuild.Memo, balance *horizon.ClaimableBalance, claimableAt time.Time) {
+	metrics.ClaimJobs.Inc()
+	defer metrics.ClaimJobs.Dec()
+
+	bot := NewConcurrentBot(s.ctx, s.wallet, conn, mainKp, sponsorKp, req.WithdrawalAddress, memo, req.Amount, req.LockedBalanceID)
 	bot.StartAggressiveBot(balance, claimableAt)
 }
 
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"pi/wallet"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferRequest struct {
+	Amount      string `json:"amount" binding:"required"`
+	Destination string `json:"destination" binding:"required"`
+	Memo        string `json:"memo"`
+	MemoType    string `json:"memo_type"`
+	FeeProfile  string `json:"fee_profile"`
+}
+
+type TransferResponse struct {
+	*wallet.TransferResult
+	Balance string `json:"balance"`
+}
+
+// Transfer sends PI from the logged in wallet. Clients may send an
+// Idempotency-Key header, retrying with the same key never pays twice.
+func (s *Server) Transfer(ctx *gin.Context) {
+	var req TransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "amount and destination are required",
+		})
+		return
+	}
+
+	memo, err := wallet.ParseMemo(req.MemoType, req.Memo)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	kp := sessionKeypair(ctx)
+	reqCtx := ctx.Request.Context()
+	if key := ctx.GetHeader("Idempotency-Key"); key != "" {
+		reqCtx = wallet.WithIdempotencyKey(reqCtx, "transfer-"+kp.Address()+"-"+key)
+	}
+
+	res, err := s.wallet.Transfer(reqCtx, kp, req.Amount, req.Destination, memo, profile)
+	if err != nil {
+		var tx *wallet.TxResult
+		if res != nil {
+			tx = res.TxResult
+		}
+		s.txError(ctx, tx, err)
+		return
+	}
+
+	// The transfer went through, a stale balance is no reason to fail it
+	balance, _ := s.wallet.GetAvailableBalance(ctx.Request.Context(), kp)
+
+	ctx.JSON(200, TransferResponse{
+		TransferResult: res,
+		Balance:        balance,
+	})
+}
+
+// txError reports a failed wallet transaction, with a machine readable code
+// for the errors clients can act on. res, if given, is included.
+func (s *Server) txError(ctx *gin.Context, res *wallet.TxResult, err error) {
+	status, code := 400, ""
+	switch {
+	case errors.Is(err, wallet.ErrTxPending):
+		// Submitted, but not confirmed yet
+		status, code = 202, "pending"
+	case errors.Is(err, wallet.ErrMemoRequired):
+		status, code = 422, "memo_required"
+	case errors.Is(err, wallet.ErrDestinationNotFunded):
+		status, code = 422, "destination_not_funded"
+	case errors.Is(err, wallet.ErrFeeCapExceeded):
+		status, code = 429, "fee_cap_exceeded"
+	case errors.Is(err, wallet.ErrInsufficientBalance):
+		status, code = 422, "insufficient_balance"
+	}
+
+	body := gin.H{
+		"message": err.Error(),
+	}
+	if code != "" {
+		body["code"] = code
+	}
+	if res != nil {
+		body["transaction"] = res
+	}
+
+	ctx.AbortWithStatusJSON(status, body)
+}
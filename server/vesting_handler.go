@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"pi/wallet"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VestingRequest struct {
+	Recipient string `json:"recipient" binding:"required"`
+	Total     string `json:"total" binding:"required"`
+	// Start defaults to now
+	Start *time.Time `json:"start"`
+	// Cliff and Interval are Go durations, or whole days such as "30d"
+	Cliff      string `json:"cliff"`
+	Periods    int    `json:"periods" binding:"required"`
+	Interval   string `json:"interval"`
+	FeeProfile string `json:"fee_profile"`
+}
+
+// CreateVesting funds a vesting schedule from the logged in wallet.
+func (s *Server) CreateVesting(ctx *gin.Context) {
+	var req VestingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "recipient, total and periods are required",
+		})
+		return
+	}
+
+	cliff, err := parseInterval(req.Cliff)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "invalid cliff: " + err.Error(),
+		})
+		return
+	}
+	interval, err := parseInterval(req.Interval)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": "invalid interval: " + err.Error(),
+		})
+		return
+	}
+
+	profile, err := wallet.ParseFeeProfile(req.FeeProfile)
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	start := time.Now()
+	if req.Start != nil {
+		start = *req.Start
+	}
+
+	schedule, err := s.wallet.CreateVesting(ctx.Request.Context(), sessionKeypair(ctx), wallet.VestingPlan{
+		Recipient: req.Recipient,
+		Total:     req.Total,
+		Start:     start,
+		Cliff:     cliff,
+		Periods:   req.Periods,
+		Interval:  interval,
+	}, profile)
+	if err != nil {
+		vestingError(ctx, schedule, err)
+		return
+	}
+
+	ctx.JSON(200, schedule)
+}
+
+// ResumeVesting funds the tranches of a schedule left unfunded by a failed
+// or pending transaction.
+func (s *Server) ResumeVesting(ctx *gin.Context) {
+	profile, err := wallet.ParseFeeProfile(ctx.Query("fee_profile"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	schedule, err := s.wallet.ResumeVesting(ctx.Request.Context(), sessionKeypair(ctx), ctx.Param("id"), profile)
+	if err != nil {
+		vestingError(ctx, schedule, err)
+		return
+	}
+
+	ctx.JSON(200, schedule)
+}
+
+func vestingError(ctx *gin.Context, schedule *wallet.VestingSchedule, err error) {
+	status, body := 400, gin.H{
+		"message": err.Error(),
+	}
+	switch {
+	case errors.Is(err, wallet.ErrScheduleNotFound):
+		status = 404
+	case errors.Is(err, wallet.ErrVestingBusy):
+		status = 409
+	case errors.Is(err, wallet.ErrTxPending):
+		// Not a failure, the schedule shows the tranches on their way
+		status, body["code"] = 202, "pending"
+	}
+	if schedule != nil {
+		// Some tranches may have been funded, the schedule says which
+		body["schedule"] = schedule
+	}
+	ctx.AbortWithStatusJSON(status, body)
+}
+
+func (s *Server) VestingSchedules(ctx *gin.Context) {
+	schedules, err := s.wallet.VestingSchedules(sessionKeypair(ctx).Address())
+	if err != nil {
+		ctx.AbortWithStatusJSON(500, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, schedules)
+}
+
+func (s *Server) VestingSchedule(ctx *gin.Context) {
+	schedule, err := s.wallet.VestingSchedule(ctx.Request.Context(), sessionKeypair(ctx).Address(), ctx.Param("id"))
+	if errors.Is(err, wallet.ErrScheduleNotFound) {
+		ctx.AbortWithStatusJSON(404, gin.H{
+			"message": wallet.ErrScheduleNotFound.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, schedule)
+}
+
+// parseInterval parses a Go duration, also accepting whole days ("30d")
+// which time.ParseDuration doesn't.
+func parseInterval(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(v)
+}
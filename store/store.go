@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Store persists JSON records as one file per key in a directory. Writes are
+// atomic, so a crash never leaves a half written record behind.
+type Store[T any] struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func Open[T any](dir string) (*Store[T], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating store directory %s: %w", dir, err)
+	}
+
+	return &Store[T]{dir: dir}, nil
+}
+
+func (s *Store[T]) path(key string) (string, error) {
+	if !validKey.MatchString(key) || strings.Trim(key, ".") == "" {
+		return "", fmt.Errorf("invalid store key %q", key)
+	}
+	return filepath.Join(s.dir, key+".json"), nil
+}
+
+func (s *Store[T]) Put(key string, v T) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding record %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error writing record %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing record %s: %w", key, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing record %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing record %s: %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing record %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the record stored under key and whether it exists.
+func (s *Store[T]) Get(key string) (T, bool, error) {
+	var v T
+
+	path, err := s.path(key)
+	if err != nil {
+		return v, false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, fmt.Errorf("error reading record %s: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false, fmt.Errorf("error decoding record %s: %w", key, err)
+	}
+	return v, true, nil
+}
+
+// List returns every record, ordered by key.
+func (s *Store[T]) List() ([]T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing store %s: %w", s.dir, err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	records := make([]T, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("error reading record %s: %w", name, err)
+		}
+
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("error decoding record %s: %w", name, err)
+		}
+		records = append(records, v)
+	}
+
+	return records, nil
+}
+
+func (s *Store[T]) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting record %s: %w", key, err)
+	}
+	return nil
+}
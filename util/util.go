@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/stellar/go/exp/crypto/derivation"
@@ -31,6 +32,19 @@ func GetKeyFromSeed(mnemonic string) (*keypair.Full, error) {
 	return kp, nil
 }
 
+// ParseKey accepts either a secret seed (S...) or a mnemonic phrase.
+func ParseKey(secret string) (*keypair.Full, error) {
+	if strings.HasPrefix(secret, "S") && !strings.Contains(secret, " ") {
+		kp, err := keypair.ParseFull(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret seed: %v", err)
+		}
+		return kp, nil
+	}
+
+	return GetKeyFromSeed(secret)
+}
+
 func GetIndexFile() (string, error) {
 	wd, err := os.Getwd()
 	if err != nil {
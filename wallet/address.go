@@ -0,0 +1,243 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// maxMemoTextLength is the protocol limit on text memos, in bytes.
+const maxMemoTextLength = 28
+
+// ValidateAddress checks that address is a well formed account (G...) or
+// muxed account (M...) address, including its checksum.
+func ValidateAddress(address string) error {
+	if strkey.IsValidEd25519PublicKey(address) || strkey.IsValidMuxedAccountEd25519PublicKey(address) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+}
+
+// validateAccountAddress is ValidateAddress for places that don't accept
+// muxed accounts, such as claimable balance claimants.
+func validateAccountAddress(address string) error {
+	if strkey.IsValidEd25519PublicKey(address) {
+		return nil
+	}
+	if strkey.IsValidMuxedAccountEd25519PublicKey(address) {
+		return fmt.Errorf("%w: muxed address %q is not allowed here", ErrInvalidAddress, address)
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+}
+
+// ParseMemo builds a memo from its type, one of none, text, id, hash or
+// return, and its value. Hash and return memos take 32 hex encoded bytes.
+// A memo type without a value is refused, an empty memo would get past
+// destinations that require one.
+func ParseMemo(memoType, value string) (txnbuild.Memo, error) {
+	switch strings.ToLower(memoType) {
+	case "", "none":
+		if value != "" {
+			return nil, fmt.Errorf("%w: memo value given without a memo type", ErrInvalidMemo)
+		}
+		return nil, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%w: %s memo has no value", ErrInvalidMemo, memoType)
+	}
+
+	switch strings.ToLower(memoType) {
+	case "text":
+		if len(value) > maxMemoTextLength {
+			return nil, fmt.Errorf("%w: text memo is %d bytes, at most %d are allowed", ErrInvalidMemo, len(value), maxMemoTextLength)
+		}
+		return txnbuild.MemoText(value), nil
+	case "id":
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id memo must be an unsigned 64-bit integer, got %q", ErrInvalidMemo, value)
+		}
+		return txnbuild.MemoID(id), nil
+	case "hash", "return":
+		b, err := hex.DecodeString(value)
+		if err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("%w: %s memo must be 32 hex encoded bytes", ErrInvalidMemo, memoType)
+		}
+		var h [32]byte
+		copy(h[:], b)
+		if strings.ToLower(memoType) == "hash" {
+			return txnbuild.MemoHash(h), nil
+		}
+		return txnbuild.MemoReturn(h), nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown memo type %q, expected none, text, id, hash or return", ErrInvalidMemo, memoType)
+}
+
+// validateAddresses checks every address the job's operations send to,
+// before anything is built or signed.
+func validateAddresses(_ context.Context, job *TxJob) error {
+	for i, op := range job.Operations {
+		var err error
+		switch op := op.(type) {
+		case *txnbuild.Payment:
+			err = ValidateAddress(op.Destination)
+		case *txnbuild.CreateAccount:
+			err = validateAccountAddress(op.Destination)
+		case *txnbuild.AccountMerge:
+			err = ValidateAddress(op.Destination)
+		case *txnbuild.CreateClaimableBalance:
+			for _, claimant := range op.Destinations {
+				if err = validateAccountAddress(claimant.Destination); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid operation %d: %w", i, err)
+		}
+
+		if source := op.GetSourceAccount(); source != "" {
+			if err := ValidateAddress(source); err != nil {
+				return fmt.Errorf("invalid operation %d source: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+const (
+	// memoRequiredKey is the SEP-29 data entry marking accounts, usually
+	// exchanges, that can't attribute incoming payments without a memo
+	memoRequiredKey = "config.memo_required"
+	// memoRequiredValue is "1", base64 encoded as Horizon serves data
+	memoRequiredValue = "MQ=="
+	// memoRequiredTTL is how long a destination's answer is reused, sparing
+	// a Horizon round trip on every payment to it
+	memoRequiredTTL = 10 * time.Minute
+	// memoRequiredCacheSize bounds the cache, expired answers are dropped
+	// once it is reached
+	memoRequiredCacheSize = 10000
+)
+
+type memoRequiredEntry struct {
+	required bool
+	at       time.Time
+}
+
+// CheckMemoRequired returns ErrMemoRequired when destination asks for a
+// memo on incoming payments (SEP-29) and memo is empty. Muxed addresses
+// already identify the recipient, and accounts that don't exist yet can't
+// ask for anything.
+func (w *Wallet) CheckMemoRequired(ctx context.Context, destination string, memo txnbuild.Memo) error {
+	if memo != nil || !strkey.IsValidEd25519PublicKey(destination) {
+		return nil
+	}
+
+	required, err := w.memoRequired(ctx, destination)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("%w: %s", ErrMemoRequired, destination)
+	}
+	return nil
+}
+
+// memoRequired reports whether destination sets the SEP-29 flag, from the
+// cache when it was looked up recently. Accounts that don't exist yet aren't
+// cached, they may be created with the flag any time.
+func (w *Wallet) memoRequired(ctx context.Context, destination string) (bool, error) {
+	w.memoMu.Lock()
+	entry, ok := w.memoCache[destination]
+	w.memoMu.Unlock()
+	if ok && time.Since(entry.at) < memoRequiredTTL {
+		return entry.required, nil
+	}
+
+	account, err := w.horizon(ctx).AccountDetail(hClient.AccountRequest{AccountID: destination})
+	if hClient.IsNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error fetching destination account: %w", err)
+	}
+	required := account.Data[memoRequiredKey] == memoRequiredValue
+
+	w.memoMu.Lock()
+	defer w.memoMu.Unlock()
+	if len(w.memoCache) >= memoRequiredCacheSize {
+		for key, e := range w.memoCache {
+			if time.Since(e.at) >= memoRequiredTTL {
+				delete(w.memoCache, key)
+			}
+		}
+	}
+	w.memoCache[destination] = memoRequiredEntry{required: required, at: time.Now()}
+	return required, nil
+}
+
+// validateMemoRequired runs CheckMemoRequired for every payment in the job.
+func (w *Wallet) validateMemoRequired(ctx context.Context, job *TxJob) error {
+	if job.Memo != nil {
+		return nil
+	}
+
+	checked := map[string]bool{}
+	for _, op := range job.Operations {
+		var destination string
+		switch op := op.(type) {
+		case *txnbuild.Payment:
+			destination = op.Destination
+		case *txnbuild.PathPaymentStrictReceive:
+			destination = op.Destination
+		case *txnbuild.PathPaymentStrictSend:
+			destination = op.Destination
+		case *txnbuild.AccountMerge:
+			destination = op.Destination
+		default:
+			continue
+		}
+		if checked[destination] {
+			continue
+		}
+		checked[destination] = true
+
+		if err := w.CheckMemoRequired(ctx, destination, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accountExists reports whether the account behind address, muxed or not,
+// exists on the network.
+func (w *Wallet) accountExists(ctx context.Context, address string) (bool, error) {
+	accountID := address
+	if strkey.IsValidMuxedAccountEd25519PublicKey(address) {
+		muxed, err := xdr.AddressToMuxedAccount(address)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+		}
+		accountID = muxed.ToAccountId().Address()
+	}
+
+	_, err := w.horizon(ctx).AccountDetail(hClient.AccountRequest{AccountID: accountID})
+	if hClient.IsNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error fetching destination account: %w", err)
+	}
+	return true, nil
+}
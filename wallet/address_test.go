@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+const (
+	testAccount = "GC2BKLYOOYPDEFJKLKY6FNNRQMGFLVHJKQRGNSSRRGSMPGF32LHCQVGF"
+	testMuxed   = "MDUJNO4HVE4YCQHV7LINPWVDQJFSAPHHUNSTT64YRBCCRZ5UYUXAWAAAAAAAAAAE2IUOE"
+)
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		valid       bool
+		validPlainG bool
+	}{
+		{"account", testAccount, true, true},
+		{"muxed", testMuxed, true, false},
+		{"bad checksum", testAccount[:len(testAccount)-1] + "A", false, false},
+		{"lower case", strings.ToLower(testAccount), false, false},
+		{"secret seed", keypair.MustRandom().Seed(), false, false},
+		{"truncated", testAccount[:40], false, false},
+		{"empty", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAddress(tt.address)
+			if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidAddress)) {
+				t.Errorf("ValidateAddress = %v, want valid %v", err, tt.valid)
+			}
+			err = validateAccountAddress(tt.address)
+			if (err == nil) != tt.validPlainG || (err != nil && !errors.Is(err, ErrInvalidAddress)) {
+				t.Errorf("validateAccountAddress = %v, want valid %v", err, tt.validPlainG)
+			}
+		})
+	}
+}
+
+func TestParseMemo(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	var hashBytes [32]byte
+	for i := range hashBytes {
+		hashBytes[i] = 0xab
+	}
+
+	tests := []struct {
+		name     string
+		memoType string
+		value    string
+		want     txnbuild.Memo
+		wantErr  bool
+	}{
+		{"no memo", "", "", nil, false},
+		{"none", "none", "", nil, false},
+		{"value without type", "", "hello", nil, true},
+		{"value with none", "none", "hello", nil, true},
+		{"text", "text", "hello", txnbuild.MemoText("hello"), false},
+		{"type is case insensitive", "TEXT", "hello", txnbuild.MemoText("hello"), false},
+		{"text at the limit", "text", strings.Repeat("a", 28), txnbuild.MemoText(strings.Repeat("a", 28)), false},
+		{"text too long", "text", strings.Repeat("a", 29), nil, true},
+		{"text counted in bytes", "text", strings.Repeat("é", 15), nil, true},
+		{"empty text", "text", "", nil, true},
+		{"id", "id", "18446744073709551615", txnbuild.MemoID(18446744073709551615), false},
+		{"id overflow", "id", "18446744073709551616", nil, true},
+		{"negative id", "id", "-1", nil, true},
+		{"empty id", "id", "", nil, true},
+		{"hash", "hash", hash, txnbuild.MemoHash(hashBytes), false},
+		{"return", "return", hash, txnbuild.MemoReturn(hashBytes), false},
+		{"short hash", "hash", hash[:62], nil, true},
+		{"hash not hex", "hash", strings.Repeat("zz", 32), nil, true},
+		{"empty hash", "hash", "", nil, true},
+		{"unknown type", "emoji", "x", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMemo(tt.memoType, tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMemo) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidMemo)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("memo = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	bad := "GNOTAKEY"
+
+	tests := []struct {
+		name    string
+		op      txnbuild.Operation
+		wantErr bool
+	}{
+		{"payment", &txnbuild.Payment{Destination: testAccount}, false},
+		{"payment to muxed", &txnbuild.Payment{Destination: testMuxed}, false},
+		{"payment to invalid", &txnbuild.Payment{Destination: bad}, true},
+		{"create account", &txnbuild.CreateAccount{Destination: testAccount}, false},
+		{"create muxed account", &txnbuild.CreateAccount{Destination: testMuxed}, true},
+		{"merge into muxed", &txnbuild.AccountMerge{Destination: testMuxed}, false},
+		{"claimants", &txnbuild.CreateClaimableBalance{Destinations: []txnbuild.Claimant{{Destination: testAccount}}}, false},
+		{"muxed claimant", &txnbuild.CreateClaimableBalance{Destinations: []txnbuild.Claimant{{Destination: testAccount}, {Destination: testMuxed}}}, true},
+		{"invalid operation source", &txnbuild.Payment{Destination: testAccount, SourceAccount: bad}, true},
+		{"muxed operation source", &txnbuild.Payment{Destination: testAccount, SourceAccount: testMuxed}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAddresses(context.Background(), &TxJob{Operations: []txnbuild.Operation{tt.op}})
+			if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrInvalidAddress)) {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
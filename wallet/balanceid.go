@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// ClaimableBalanceID computes the ID of the balance created by the
+// operation at opIndex of the transaction with the given source account
+// and sequence number, the same way Stellar Core does.
+func ClaimableBalanceID(source string, sequence int64, opIndex int) (string, error) {
+	muxed, err := xdr.AddressToMuxedAccount(source)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAddress, source)
+	}
+
+	preimage := xdr.HashIdPreimage{
+		Type: xdr.EnvelopeTypeEnvelopeTypeOpId,
+		OperationId: &xdr.HashIdPreimageOperationId{
+			SourceAccount: muxed.ToAccountId(),
+			SeqNum:        xdr.SequenceNumber(sequence),
+			OpNum:         xdr.Uint32(opIndex),
+		},
+	}
+	b, err := preimage.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("error encoding operation id: %w", err)
+	}
+
+	id, err := xdr.NewClaimableBalanceId(xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0, xdr.Hash(sha256.Sum256(b)))
+	if err != nil {
+		return "", err
+	}
+	return xdr.MarshalHex(id)
+}
+
+// BalanceIDs returns the IDs of the balances a transaction envelope creates,
+// in operation order. They are known as soon as the transaction is built.
+func BalanceIDs(envelopeXDR string) ([]string, error) {
+	ops, err := balanceOps(envelopeXDR)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(ops))
+	for i, op := range ops {
+		ids[i] = op.id
+	}
+	return ids, nil
+}
+
+type balanceOp struct {
+	index int
+	id    string
+}
+
+// balanceOps returns the create claimable balance operations of an
+// envelope, with the ID of the balance each creates.
+func balanceOps(envelopeXDR string) ([]balanceOp, error) {
+	generic, err := txnbuild.TransactionFromXDR(envelopeXDR)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding transaction: %w", err)
+	}
+	tx, ok := generic.Transaction()
+	if !ok {
+		fee, _ := generic.FeeBump()
+		tx = fee.InnerTransaction()
+	}
+
+	source := tx.SourceAccount()
+	var ops []balanceOp
+	for i, op := range tx.Operations() {
+		if _, ok := op.(*txnbuild.CreateClaimableBalance); !ok {
+			continue
+		}
+
+		id, err := ClaimableBalanceID(source.AccountID, source.Sequence, i)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, balanceOp{index: i, id: id})
+	}
+	return ops, nil
+}
+
+// VerifyBalanceID checks that balanceID was created by the transaction with
+// the given hash, returning the index of the operation that created it. Only
+// successful transactions create balances.
+func (w *Wallet) VerifyBalanceID(ctx context.Context, txHash, balanceID string) (int, error) {
+	tx, err := w.horizon(ctx).TransactionDetail(txHash)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching transaction: %w", err)
+	}
+	if !tx.Successful {
+		return 0, fmt.Errorf("%w: transaction %s failed", ErrBalanceIDMismatch, txHash)
+	}
+
+	ops, err := balanceOps(tx.EnvelopeXdr)
+	if err != nil {
+		return 0, err
+	}
+	for _, op := range ops {
+		if strings.EqualFold(op.id, balanceID) {
+			return op.index, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s was not created by %s", ErrBalanceIDMismatch, balanceID, txHash)
+}
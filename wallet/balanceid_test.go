@@ -0,0 +1,66 @@
+package wallet
+
+import "testing"
+
+func TestClaimableBalanceID(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		sequence int64
+		opIndex  int
+		want     string
+	}{
+		{
+			// Balance ID from a CreateClaimableBalanceResult returned by the network
+			name:     "muxed source, from network result",
+			source:   "MDUJNO4HVE4YCQHV7LINPWVDQJFSAPHHUNSTT64YRBCCRZ5UYUXAWAAAAAAAAAAE2IUOE",
+			sequence: 5894915628204035,
+			want:     "0000000070d89659608b789ff59729c9c3a30de66d304c3eb3a795d7ad82f1949694d211",
+		},
+		{
+			name:     "first sequence",
+			source:   "GC2BKLYOOYPDEFJKLKY6FNNRQMGFLVHJKQRGNSSRRGSMPGF32LHCQVGF",
+			sequence: 1,
+			want:     "000000000bf0a78c7ca2a980768b66980ba97934f3b3b45a05ce7a5195a44b64b7dedadb",
+		},
+		{
+			name:     "later sequence",
+			source:   "GC2BKLYOOYPDEFJKLKY6FNNRQMGFLVHJKQRGNSSRRGSMPGF32LHCQVGF",
+			sequence: 124,
+			want:     "0000000095001252ab3b4d16adbfa5364ce526dfcda03cb2258b827edbb2e0450087be51",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClaimableBalanceID(tt.source, tt.sequence, tt.opIndex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimableBalanceIDOperationIndex(t *testing.T) {
+	source := "GC2BKLYOOYPDEFJKLKY6FNNRQMGFLVHJKQRGNSSRRGSMPGF32LHCQVGF"
+	first, err := ClaimableBalanceID(source, 124, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ClaimableBalanceID(source, 124, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("operations 0 and 1 share balance ID %s", first)
+	}
+}
+
+func TestClaimableBalanceIDInvalidSource(t *testing.T) {
+	if _, err := ClaimableBalanceID("not-an-address", 1, 0); err == nil {
+		t.Error("expected an error for an invalid source")
+	}
+}
@@ -0,0 +1,485 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+)
+
+// maxBatchRows keeps a batch to a few dozen transactions.
+const maxBatchRows = 5000
+
+const (
+	PaymentInvalid = "invalid"
+	PaymentQueued  = "queued"
+	PaymentPending = "pending"
+	PaymentPaid    = "paid"
+	PaymentFailed  = "failed"
+)
+
+const (
+	BatchInvalid     = "invalid"
+	BatchReady       = "ready"
+	BatchRunning     = "running"
+	BatchDone        = "done"
+	BatchInterrupted = "interrupted"
+)
+
+// BatchPayment is one row of a batch and what became of it.
+type BatchPayment struct {
+	Row         int    `json:"row"`
+	Destination string `json:"destination"`
+	Amount      string `json:"amount"`
+	MemoType    string `json:"memo_type,omitempty"`
+	Memo        string `json:"memo,omitempty"`
+	// CreateAccount is set for destinations that don't exist yet
+	CreateAccount bool   `json:"create_account"`
+	Status        string `json:"status"`
+	TxHash        string `json:"tx_hash,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Batch is a persisted list of payments from one account.
+type Batch struct {
+	ID         string         `json:"id"`
+	Source     string         `json:"source"`
+	FeeProfile FeeProfile     `json:"fee_profile"`
+	Status     string         `json:"status"`
+	Total      string         `json:"total"`
+	Payments   []BatchPayment `json:"payments"`
+	LastError  string         `json:"last_error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// ParseBatchCSV reads payments from CSV rows of destination, amount and
+// an optional memo and memo type. Memos are text unless a type is given.
+// A header row is skipped if present.
+func ParseBatchCSV(r io.Reader) ([]BatchPayment, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var payments []BatchPayment
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %w", err)
+		}
+		// Rows are numbered by line, as a spreadsheet shows them
+		row, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "destination") {
+			continue
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 || len(record) > 4 {
+			return nil, fmt.Errorf("row %d: expected destination, amount, memo and memo type, got %d fields", row, len(record))
+		}
+
+		p := BatchPayment{
+			Row:         row,
+			Destination: strings.TrimSpace(record[0]),
+			Amount:      strings.TrimSpace(record[1]),
+		}
+		if len(record) > 2 {
+			p.Memo = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 {
+			p.MemoType = strings.ToLower(strings.TrimSpace(record[3]))
+		}
+		if p.MemoType == "" && p.Memo != "" {
+			p.MemoType = "text"
+		}
+		payments = append(payments, p)
+
+		if len(payments) > maxBatchRows {
+			return nil, fmt.Errorf("a batch can have at most %d payments", maxBatchRows)
+		}
+	}
+
+	if len(payments) == 0 {
+		return nil, errors.New("no payments in csv")
+	}
+	return payments, nil
+}
+
+// NewBatch validates every payment and stores the batch. If any row is
+// invalid the batch is stored with status invalid, the reason noted on
+// each row, and ErrBatchInvalid is returned. Nothing is sent either way,
+// see RunBatch.
+func (w *Wallet) NewBatch(ctx context.Context, kp *keypair.Full, payments []BatchPayment, profile FeeProfile) (*Batch, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	batch := &Batch{
+		ID:         hex.EncodeToString(id),
+		Source:     kp.Address(),
+		FeeProfile: profile,
+		Status:     BatchReady,
+		Payments:   payments,
+		CreatedAt:  time.Now(),
+	}
+
+	reserve, err := w.loadedBaseReserve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := w.validateBatch(ctx, batch, reserve)
+	if err != nil {
+		batch.Status = BatchInvalid
+		batch.LastError = err.Error()
+	}
+	batch.Total = amount.StringFromInt64(total)
+
+	if err == nil {
+		err = w.checkBatchFunds(ctx, kp, batch, total, reserve)
+		if err != nil {
+			batch.Status = BatchInvalid
+			batch.LastError = err.Error()
+		}
+	}
+
+	if putErr := w.putBatch(batch); putErr != nil {
+		return nil, putErr
+	}
+	return batch, err
+}
+
+// validateBatch checks each row on its own and against the network,
+// returning the total of the batch in stroops. baseReserve is in stroops.
+func (w *Wallet) validateBatch(ctx context.Context, batch *Batch, baseReserve int64) (int64, error) {
+	minCreate := 2 * baseReserve
+
+	exists := map[string]bool{}
+	// An account can only be created once, by the first row paying it
+	created := map[string]int{}
+	var total int64
+	invalid := 0
+	for i := range batch.Payments {
+		p := &batch.Payments[i]
+		p.Status = PaymentQueued
+
+		err := func() error {
+			if err := ValidateAddress(p.Destination); err != nil {
+				return err
+			}
+			if p.Destination == batch.Source {
+				return errors.New("destination is the paying account")
+			}
+
+			stroops, err := amount.ParseInt64(p.Amount)
+			if err != nil || stroops <= 0 {
+				return fmt.Errorf("invalid amount %q", p.Amount)
+			}
+
+			memo, err := ParseMemo(p.MemoType, p.Memo)
+			if err != nil {
+				return err
+			}
+
+			found, checked := exists[p.Destination]
+			if !checked {
+				found, err = w.accountExists(ctx, p.Destination)
+				if err != nil {
+					return err
+				}
+				exists[p.Destination] = found
+			}
+			if !found {
+				if strkey.IsValidMuxedAccountEd25519PublicKey(p.Destination) {
+					return fmt.Errorf("%w: muxed address belongs to an account that does not exist yet", ErrDestinationNotFunded)
+				}
+				if stroops < minCreate {
+					return fmt.Errorf("%w: creating it needs at least %s PI", ErrDestinationNotFunded, amount.StringFromInt64(minCreate))
+				}
+				if row, ok := created[p.Destination]; ok {
+					return fmt.Errorf("%w: row %d already creates this account, pay it in a single row", ErrDestinationNotFunded, row)
+				}
+				created[p.Destination] = p.Row
+				p.CreateAccount = true
+			}
+
+			if err := w.CheckMemoRequired(ctx, p.Destination, memo); err != nil {
+				return err
+			}
+
+			total += stroops
+			return nil
+		}()
+		if err != nil {
+			p.Status = PaymentInvalid
+			p.Error = err.Error()
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		return total, fmt.Errorf("%w: %d of %d rows are invalid", ErrBatchInvalid, invalid, len(batch.Payments))
+	}
+	return total, nil
+}
+
+// checkBatchFunds makes sure the source can pay the whole batch and its
+// fees while keeping its minimum balance.
+func (w *Wallet) checkBatchFunds(ctx context.Context, kp *keypair.Full, batch *Batch, total, baseReserve int64) error {
+	account, err := w.GetAccount(ctx, kp)
+	if err != nil {
+		return err
+	}
+	native, err := account.GetNativeBalance()
+	if err != nil {
+		return fmt.Errorf("invalid balance format: %w", err)
+	}
+	balance, err := amount.ParseInt64(native)
+	if err != nil {
+		return fmt.Errorf("invalid balance format: %w", err)
+	}
+
+	fee, err := w.EstimateFee(ctx, batch.FeeProfile)
+	if err != nil {
+		return fmt.Errorf("error choosing fee: %w", err)
+	}
+
+	reserve := baseReserve * int64(2+account.SubentryCount)
+	needed := total + fee*int64(len(batch.Payments)) + reserve
+	if balance < needed {
+		return fmt.Errorf("%w: batch needs %s PI including fees and reserve, balance is %s PI", ErrBatchInvalid, amount.StringFromInt64(needed), native)
+	}
+	return nil
+}
+
+// batchChunks groups the payments of a batch into transactions. A
+// transaction has a single memo, so only payments sharing a memo go
+// together. The grouping only depends on the rows, so a resumed batch
+// sends the same transactions under the same idempotency keys.
+func batchChunks(payments []BatchPayment) [][]int {
+	var order []string
+	groups := map[string][]int{}
+	for i, p := range payments {
+		if p.Status == PaymentInvalid {
+			continue
+		}
+		key := p.MemoType + ":" + p.Memo
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var chunks [][]int
+	for _, key := range order {
+		rows := groups[key]
+		for start := 0; start < len(rows); start += maxOpsPerTx {
+			chunks = append(chunks, rows[start:min(start+maxOpsPerTx, len(rows))])
+		}
+	}
+	return chunks
+}
+
+// RunBatch sends the payments of a stored batch one transaction at a time,
+// updating the status of each row as it goes. A transaction the network
+// rejects fails its rows and the batch moves on; anything else, including
+// a transaction still pending, interrupts the batch. Running it again
+// resumes with the rows that aren't paid yet.
+func (w *Wallet) RunBatch(ctx context.Context, kp *keypair.Full, id string) (*Batch, error) {
+	w.batchMu.Lock()
+	if w.batchRunning[id] {
+		w.batchMu.Unlock()
+		return nil, ErrBatchRunning
+	}
+	w.batchRunning[id] = true
+	w.batchMu.Unlock()
+	defer func() {
+		w.batchMu.Lock()
+		delete(w.batchRunning, id)
+		w.batchMu.Unlock()
+	}()
+
+	batch, err := w.Batch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Source != kp.Address() {
+		return nil, ErrBatchNotFound
+	}
+	if batch.Status == BatchInvalid {
+		return batch, ErrBatchInvalid
+	}
+
+	batch.Status = BatchRunning
+	batch.LastError = ""
+	if err := w.putBatch(batch); err != nil {
+		return nil, err
+	}
+
+	for n, rows := range batch.chunks() {
+		if batch.paid(rows) {
+			continue
+		}
+
+		first := batch.Payments[rows[0]]
+		memo, _ := ParseMemo(first.MemoType, first.Memo)
+		ops := make([]txnbuild.Operation, len(rows))
+		for i, row := range rows {
+			p := batch.Payments[row]
+			if p.CreateAccount {
+				ops[i] = &txnbuild.CreateAccount{Destination: p.Destination, Amount: p.Amount}
+			} else {
+				ops[i] = &txnbuild.Payment{Destination: p.Destination, Amount: p.Amount, Asset: txnbuild.NativeAsset{}}
+			}
+		}
+
+		res, err := w.Run(WithIdempotencyKey(ctx, "batch-"+batch.ID+"-"+strconv.Itoa(n)), &TxJob{
+			Label:      "batch payment",
+			Source:     kp,
+			Operations: ops,
+			Memo:       memo,
+			FeeProfile: batch.FeeProfile,
+		})
+
+		var failed *TxFailedError
+		for i, row := range rows {
+			p := &batch.Payments[row]
+			if res != nil {
+				p.TxHash = res.Hash
+			}
+			p.Error = ""
+			switch {
+			case err == nil:
+				p.Status = PaymentPaid
+			case errors.Is(err, ErrTxPending):
+				p.Status = PaymentPending
+			case errors.As(err, &failed):
+				p.Status = PaymentFailed
+				p.Error = failed.ResultCode
+				if i < len(failed.OperationCodes) && failed.OperationCodes[i] != "op_success" {
+					p.Error = failed.OperationCodes[i]
+				}
+			default:
+				p.Status = PaymentFailed
+				p.Error = err.Error()
+			}
+		}
+
+		interrupted := err != nil && !errors.As(err, &failed)
+		if interrupted {
+			batch.Status = BatchInterrupted
+			batch.LastError = err.Error()
+		}
+		if putErr := w.putBatch(batch); putErr != nil {
+			return batch, putErr
+		}
+		if interrupted {
+			return batch, fmt.Errorf("batch interrupted at transaction %d: %w", n, err)
+		}
+	}
+
+	batch.Status = BatchDone
+	return batch, w.putBatch(batch)
+}
+
+func (b *Batch) chunks() [][]int {
+	return batchChunks(b.Payments)
+}
+
+func (b *Batch) paid(rows []int) bool {
+	for _, row := range rows {
+		if b.Payments[row].Status != PaymentPaid {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Wallet) putBatch(batch *Batch) error {
+	batch.UpdatedAt = time.Now()
+	return w.batchStore.Put(batch.ID, *batch)
+}
+
+// Batch returns a stored batch.
+func (w *Wallet) Batch(id string) (*Batch, error) {
+	batch, found, err := w.batchStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrBatchNotFound
+	}
+	w.markStale(&batch)
+	return &batch, nil
+}
+
+// BatchActive reports whether the batch is being sent right now.
+func (w *Wallet) BatchActive(id string) bool {
+	w.batchMu.Lock()
+	defer w.batchMu.Unlock()
+	return w.batchRunning[id]
+}
+
+// markStale reports a batch stored as running that nothing is sending as
+// interrupted, as a crash or kill mid-batch leaves it.
+func (w *Wallet) markStale(batch *Batch) {
+	if batch.Status == BatchRunning && !w.BatchActive(batch.ID) {
+		batch.Status = BatchInterrupted
+		if batch.LastError == "" {
+			batch.LastError = "stopped while running"
+		}
+	}
+}
+
+// Batches lists the batches paid from source.
+func (w *Wallet) Batches(source string) ([]Batch, error) {
+	all, err := w.batchStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	batches := []Batch{}
+	for _, b := range all {
+		if b.Source == source {
+			w.markStale(&b)
+			batches = append(batches, b)
+		}
+	}
+	return batches, nil
+}
+
+// WriteReport writes the outcome of every row as CSV.
+func (b *Batch) WriteReport(out io.Writer) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"row", "destination", "amount", "memo", "memo_type", "create_account", "status", "tx_hash", "error"})
+	for _, p := range b.Payments {
+		writer.Write([]string{
+			strconv.Itoa(p.Row),
+			p.Destination,
+			p.Amount,
+			p.Memo,
+			p.MemoType,
+			strconv.FormatBool(p.CreateAccount),
+			p.Status,
+			p.TxHash,
+			p.Error,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []BatchPayment
+		wantErr string
+	}{
+		{
+			name: "header and memos",
+			csv:  "destination,amount,memo,memo_type\n" + testAccount + ",1.5\n" + testAccount + ", 2 ,hello\n" + testMuxed + ",3,42,ID\n",
+			want: []BatchPayment{
+				{Row: 2, Destination: testAccount, Amount: "1.5"},
+				{Row: 3, Destination: testAccount, Amount: "2", Memo: "hello", MemoType: "text"},
+				{Row: 4, Destination: testMuxed, Amount: "3", Memo: "42", MemoType: "id"},
+			},
+		},
+		{
+			name: "no header, blank lines skipped",
+			csv:  testAccount + ",1\n\n" + testAccount + ",2\n",
+			want: []BatchPayment{
+				{Row: 1, Destination: testAccount, Amount: "1"},
+				{Row: 3, Destination: testAccount, Amount: "2"},
+			},
+		},
+		{
+			name: "header is case insensitive",
+			csv:  "Destination,Amount\r\n" + testAccount + ",1\r\n",
+			want: []BatchPayment{
+				{Row: 2, Destination: testAccount, Amount: "1"},
+			},
+		},
+		{
+			name: "quoted memo with a comma",
+			csv:  testAccount + `,1,"a, b"` + "\n",
+			want: []BatchPayment{
+				{Row: 1, Destination: testAccount, Amount: "1", Memo: "a, b", MemoType: "text"},
+			},
+		},
+		{
+			name:    "too few fields",
+			csv:     testAccount + ",1\n" + testAccount + "\n",
+			wantErr: "row 2",
+		},
+		{
+			name:    "too many fields",
+			csv:     testAccount + ",1,memo,text,extra\n",
+			wantErr: "row 1",
+		},
+		{
+			name:    "unterminated quote",
+			csv:     testAccount + `,1,"memo` + "\n",
+			wantErr: "error reading csv",
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: "no payments",
+		},
+		{
+			name:    "header only",
+			csv:     "destination,amount\n",
+			wantErr: "no payments",
+		},
+		{
+			name:    "too many rows",
+			csv:     strings.Repeat(testAccount+",1\n", maxBatchRows+1),
+			wantErr: fmt.Sprintf("at most %d payments", maxBatchRows),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBatchCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+// maxClaimants is the protocol limit on claimants of one balance.
+const maxClaimants = 10
+
+// ClaimableBalance builds a claimable balance of native PI:
+//
+//	NewClaimableBalance("100").
+//		To(recipient, After(unlock)).
+//		To(sender, Not(After(unlock)))
+//
+// The first error sticks and is reported by Operation.
+type ClaimableBalance struct {
+	amount    string
+	claimants []txnbuild.Claimant
+	err       error
+}
+
+// NewClaimableBalance starts a balance of exactly amount PI, with at most
+// seven decimals.
+func NewClaimableBalance(amountStr string) *ClaimableBalance {
+	b := &ClaimableBalance{amount: amountStr}
+
+	stroops, err := amount.ParseInt64(amountStr)
+	if err != nil {
+		b.err = fmt.Errorf("invalid amount %q: %w", amountStr, err)
+	} else if stroops <= 0 {
+		b.err = fmt.Errorf("invalid amount %q: must be positive", amountStr)
+	}
+	return b
+}
+
+// To adds a claimant who may claim the balance while predicate holds.
+func (b *ClaimableBalance) To(destination string, predicate Predicate) *ClaimableBalance {
+	if b.err != nil {
+		return b
+	}
+
+	if err := validateAccountAddress(destination); err != nil {
+		b.err = fmt.Errorf("invalid claimant: %w", err)
+		return b
+	}
+	for _, c := range b.claimants {
+		if c.Destination == destination {
+			b.err = fmt.Errorf("claimant %s is listed twice", destination)
+			return b
+		}
+	}
+	if len(b.claimants) == maxClaimants {
+		b.err = fmt.Errorf("a balance can have at most %d claimants", maxClaimants)
+		return b
+	}
+
+	pred, err := predicate.XDR()
+	if err != nil {
+		b.err = fmt.Errorf("invalid predicate for %s: %w", destination, err)
+		return b
+	}
+
+	b.claimants = append(b.claimants, txnbuild.Claimant{Destination: destination, Predicate: pred})
+	return b
+}
+
+func (b *ClaimableBalance) Amount() string {
+	return b.amount
+}
+
+// Operation returns the CreateClaimableBalance operation for the balance.
+func (b *ClaimableBalance) Operation() (*txnbuild.CreateClaimableBalance, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.claimants) == 0 {
+		return nil, fmt.Errorf("a balance needs at least one claimant")
+	}
+
+	return &txnbuild.CreateClaimableBalance{
+		Asset:        txnbuild.NativeAsset{},
+		Amount:       b.amount,
+		Destinations: b.claimants,
+	}, nil
+}
+
+// ClaimableResult is the outcome of creating claimable balances, with the
+// IDs of the balances in the order they were given. The IDs are set for
+// dry runs and pending transactions too.
+type ClaimableResult struct {
+	*TxResult
+	BalanceIDs []string `json:"balance_ids"`
+}
+
+// CreateClaimable creates the balances in a single transaction.
+func (w *Wallet) CreateClaimable(ctx context.Context, kp *keypair.Full, memo txnbuild.Memo, profile FeeProfile, balances ...*ClaimableBalance) (*ClaimableResult, error) {
+	if len(balances) == 0 {
+		return nil, fmt.Errorf("no balances to create")
+	}
+
+	ops := make([]txnbuild.Operation, 0, len(balances))
+	for i, b := range balances {
+		op, err := b.Operation()
+		if err != nil {
+			return nil, fmt.Errorf("balance %d: %w", i, err)
+		}
+		ops = append(ops, op)
+	}
+
+	res, err := w.Run(ctx, &TxJob{
+		Label:      "create claimable balance",
+		Source:     kp,
+		Operations: ops,
+		Memo:       memo,
+		FeeProfile: profile,
+	})
+	if res == nil {
+		return nil, err
+	}
+
+	// IDs depend only on the source, sequence and operation index, so they
+	// are known even if the transaction is still pending
+	result := &ClaimableResult{TxResult: res}
+	ids, idErr := BalanceIDs(res.EnvelopeXDR)
+	if idErr != nil {
+		return result, fmt.Errorf("error computing balance IDs: %w", idErr)
+	}
+	result.BalanceIDs = ids
+	return result, err
+}
+
+// ReclaimableBy lets sender take the balance back once expiry has passed,
+// should the other claimants not have claimed it by then.
+func (b *ClaimableBalance) ReclaimableBy(sender string, expiry time.Time) *ClaimableBalance {
+	return b.To(sender, After(expiry))
+}
@@ -0,0 +1,592 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// CosignCollecting transactions are waiting for signatures
+	CosignCollecting = "collecting"
+	// CosignSubmitted transactions met their threshold and were sent, the
+	// result says how that went
+	CosignSubmitted = "submitted"
+	CosignExpired   = "expired"
+	CosignDiscarded = "discarded"
+)
+
+const (
+	// DefaultCosignWindow is how long signatures are collected by default
+	DefaultCosignWindow = 24 * time.Hour
+	// MaxCosignWindow bounds how long a proposal can stay open
+	MaxCosignWindow = 7 * 24 * time.Hour
+	// cosignSweep is how often expired proposals are looked for
+	cosignSweep = time.Minute
+)
+
+type CollectedSignature struct {
+	Signer string    `json:"signer"`
+	Weight int32     `json:"weight"`
+	At     time.Time `json:"at"`
+}
+
+// PendingTx is a transaction of a multisig account waiting for its
+// signers. The envelope carries every valid signature collected so far.
+type PendingTx struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Source      string `json:"source"`
+	Proposer    string `json:"proposer"`
+	EnvelopeXDR string `json:"envelope_xdr"`
+	// Level is the threshold the operations need and Required its weight
+	Level      string               `json:"level"`
+	Required   int32                `json:"required"`
+	Weight     int32                `json:"weight"`
+	Signers    []AccountSigner      `json:"signers"`
+	Signatures []CollectedSignature `json:"signatures"`
+	Status     string               `json:"status"`
+	Result     *TxResult            `json:"result,omitempty"`
+	LastError  string               `json:"last_error,omitempty"`
+	ExpiresAt  time.Time            `json:"expires_at"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+
+	// Key and Amount come from the job that built the transaction, and are
+	// recorded with it once it is sent
+	Key    string `json:"key,omitempty"`
+	Amount string `json:"amount,omitempty"`
+}
+
+// CanSign reports whether address is one of the signers of the proposal.
+func (p *PendingTx) CanSign(address string) bool {
+	for _, s := range p.Signers {
+		if s.Key == address {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLevel is the highest threshold any of the operations needs.
+func requiredLevel(ops []txnbuild.Operation) string {
+	level := ThresholdLow
+	for _, op := range ops {
+		switch operationThreshold(op) {
+		case ThresholdHigh:
+			return ThresholdHigh
+		case ThresholdMedium:
+			level = ThresholdMedium
+		}
+	}
+	return level
+}
+
+// Propose builds a transaction for the multisig account source, signed by
+// kp, and keeps it until its signers reach the threshold or window passes.
+// The transaction uses the next sequence number of source, so any other
+// transaction from source in the meantime makes it unusable.
+func (w *Wallet) Propose(ctx context.Context, kp *keypair.Full, source string, job *TxJob, window time.Duration) (*PendingTx, error) {
+	if window <= 0 || window > MaxCosignWindow {
+		return nil, fmt.Errorf("collection window must be positive and at most %s", MaxCosignWindow)
+	}
+	for i, op := range job.Operations {
+		if s := op.GetSourceAccount(); s != "" && s != source {
+			return nil, fmt.Errorf("operation %d has its own source account, only %s can be signed for", i, source)
+		}
+	}
+
+	account, err := w.horizon(ctx).AccountDetail(hClient.AccountRequest{AccountID: source})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching account details: %w", err)
+	}
+	info := newAccountInfo(account)
+	if info.signerWeight(kp.Address()) == 0 {
+		return nil, fmt.Errorf("%w: %s is not a signer of %s", ErrUnAuthorized, kp.Address(), source)
+	}
+
+	job.Source = kp
+	job.Account = &account
+	job.Signers = []*keypair.Full{kp}
+	job.Timeout = window
+	// The ledger window would cut the collection window short
+	job.NoLedgerWindow = true
+	job.DryRun = true
+
+	res, err := w.Run(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	level := requiredLevel(job.Operations)
+	p := &PendingTx{
+		ID:          res.Hash,
+		Label:       job.Label,
+		Source:      source,
+		Proposer:    kp.Address(),
+		EnvelopeXDR: res.EnvelopeXDR,
+		Level:       level,
+		Required:    info.Thresholds.weight(level),
+		Signers:     info.Signers,
+		Status:      CosignCollecting,
+		ExpiresAt:   time.Unix(res.MaxTime, 0).UTC(),
+		CreatedAt:   time.Now(),
+		Key:         job.Key,
+		Amount:      job.Amount,
+	}
+
+	w.cosignMu.Lock()
+	tx, err := w.collect(p, nil)
+	w.cosignMu.Unlock()
+	if err != nil || tx == nil {
+		return p, err
+	}
+	return p, w.submitPending(ctx, p, tx)
+}
+
+// ProposePayment proposes a payment from the multisig account source.
+func (w *Wallet) ProposePayment(ctx context.Context, kp *keypair.Full, source, destination, amountStr string, memo txnbuild.Memo, profile FeeProfile, window time.Duration) (*PendingTx, error) {
+	exists, err := w.accountExists(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrDestinationNotFunded, destination)
+	}
+
+	return w.Propose(ctx, kp, source, &TxJob{
+		Label: "multisig payment",
+		Operations: []txnbuild.Operation{&txnbuild.Payment{
+			Destination: destination,
+			Amount:      amountStr,
+			Asset:       txnbuild.NativeAsset{},
+		}},
+		Memo:       memo,
+		FeeProfile: profile,
+		Amount:     amountStr,
+	}, window)
+}
+
+// ProposeSignerUpdate proposes changing the signers of the multisig account
+// source, refusing changes that would lock it out.
+func (w *Wallet) ProposeSignerUpdate(ctx context.Context, kp *keypair.Full, source string, update SignerUpdate, profile FeeProfile, window time.Duration) (*PendingTx, error) {
+	ops := update.operations()
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("nothing to update")
+	}
+
+	info, err := w.AccountInfo(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := update.apply(info); err != nil {
+		return nil, err
+	}
+
+	return w.Propose(ctx, kp, source, &TxJob{
+		Label:      "update signers",
+		Operations: ops,
+		FeeProfile: profile,
+	}, window)
+}
+
+// SignPending adds kp's signature to a proposal.
+func (w *Wallet) SignPending(ctx context.Context, kp *keypair.Full, id string) (*PendingTx, error) {
+	return w.addSignatures(ctx, id, kp.Address(), func(tx *txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		return tx.Sign(w.networkPassphrase, kp)
+	})
+}
+
+// AddSignature adds a signature made elsewhere, base64 encoded, by the
+// account signer over the transaction hash.
+func (w *Wallet) AddSignature(ctx context.Context, id, signer, signature string) (*PendingTx, error) {
+	return w.addSignatures(ctx, id, signer, func(tx *txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		signed, err := tx.AddSignatureBase64(w.networkPassphrase, signer, signature)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		return signed, nil
+	})
+}
+
+// AddEnvelope merges the signatures of a signed copy of the proposal, as
+// produced by wallets that sign whole envelopes.
+func (w *Wallet) AddEnvelope(ctx context.Context, id, envelopeXDR string) (*PendingTx, error) {
+	return w.addSignatures(ctx, id, "", func(tx *txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		generic, err := txnbuild.TransactionFromXDR(envelopeXDR)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding envelope: %w", err)
+		}
+		other, ok := generic.Transaction()
+		if !ok {
+			return nil, errors.New("envelope is not a regular transaction")
+		}
+		hash, err := other.HashHex(w.networkPassphrase)
+		if err != nil || hash != id {
+			return nil, fmt.Errorf("envelope is not transaction %s", id)
+		}
+		return tx.AddSignatureDecorated(other.Signatures()...)
+	})
+}
+
+// addSignatures applies sign to a proposal still collecting signatures,
+// submitting it once they weigh enough. A signer, when given, must be one
+// of the proposal's signers.
+func (w *Wallet) addSignatures(ctx context.Context, id, signer string, sign func(*txnbuild.Transaction) (*txnbuild.Transaction, error)) (*PendingTx, error) {
+	p, tx, err := w.collectSignatures(id, signer, sign)
+	if err != nil || tx == nil {
+		return p, err
+	}
+	return p, w.submitPending(ctx, p, tx)
+}
+
+func (w *Wallet) collectSignatures(id, signer string, sign func(*txnbuild.Transaction) (*txnbuild.Transaction, error)) (*PendingTx, *txnbuild.Transaction, error) {
+	w.cosignMu.Lock()
+	defer w.cosignMu.Unlock()
+
+	p, err := w.PendingTx(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Status != CosignCollecting {
+		return p, nil, fmt.Errorf("%w: transaction is %s", ErrNotCollecting, p.Status)
+	}
+	if !time.Now().Before(p.ExpiresAt) {
+		p.Status = CosignExpired
+		return p, nil, errors.Join(ErrNotCollecting, w.putPendingTx(p))
+	}
+	if signer != "" && !p.CanSign(signer) {
+		return p, nil, fmt.Errorf("%w: %s is not a signer of %s", ErrUnAuthorized, signer, p.Source)
+	}
+
+	tx, err := w.collect(p, sign)
+	return p, tx, err
+}
+
+// collect applies sign to the proposal's envelope and keeps only signatures
+// of its signers. Once they weigh enough the proposal is stored as
+// submitted and the transaction to send is returned. Callers hold cosignMu.
+func (w *Wallet) collect(p *PendingTx, sign func(*txnbuild.Transaction) (*txnbuild.Transaction, error)) (*txnbuild.Transaction, error) {
+	tx, err := decodeTx(p.EnvelopeXDR)
+	if err != nil {
+		return nil, err
+	}
+	if sign != nil {
+		if tx, err = sign(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	hash, err := tx.Hash(w.networkPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing transaction: %w", err)
+	}
+	valid := verifySignatures(hash, tx.Signatures(), p.Signers)
+
+	// Keep the time each signer first signed
+	signedAt := map[string]time.Time{}
+	for _, s := range p.Signatures {
+		signedAt[s.Signer] = s.At
+	}
+	p.Signatures = []CollectedSignature{}
+	p.Weight = 0
+	for _, v := range valid {
+		at, ok := signedAt[v.signer]
+		if !ok {
+			at = time.Now()
+		}
+		p.Signatures = append(p.Signatures, CollectedSignature{Signer: v.signer, Weight: v.weight, At: at})
+		p.Weight += v.weight
+	}
+
+	if p.Weight < p.Required {
+		if tx, err = withSignatures(tx, valid); err != nil {
+			return nil, err
+		}
+		if p.EnvelopeXDR, err = tx.Base64(); err != nil {
+			return nil, fmt.Errorf("error encoding transaction: %w", err)
+		}
+		return nil, w.putPendingTx(p)
+	}
+
+	// Signatures beyond what the threshold needs fail the transaction with
+	// tx_bad_auth_extra, so only a minimal set goes out. Taking the heaviest
+	// first, every one of them is needed.
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].weight > valid[j].weight })
+	var weight int32
+	for i, v := range valid {
+		weight += v.weight
+		if weight >= p.Required {
+			valid = valid[:i+1]
+			break
+		}
+	}
+	if tx, err = withSignatures(tx, valid); err != nil {
+		return nil, err
+	}
+	if p.EnvelopeXDR, err = tx.Base64(); err != nil {
+		return nil, fmt.Errorf("error encoding transaction: %w", err)
+	}
+
+	// Nobody else touches a submitted proposal, so it is sent without
+	// holding up the others
+	p.Status = CosignSubmitted
+	if err := w.putPendingTx(p); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// submitPending sends a proposal that met its threshold and records the
+// outcome. It runs without cosignMu, sending can take the whole confirm
+// timeout.
+func (w *Wallet) submitPending(ctx context.Context, p *PendingTx, tx *txnbuild.Transaction) error {
+	// Cosigned transactions have no ledger bounds, see Propose
+	rec, err := w.newTxRecord(&TxJob{
+		Key:     p.Key,
+		Label:   p.Label,
+		Account: &horizon.Account{AccountID: p.Source},
+		Amount:  p.Amount,
+	}, tx)
+	var res *TxResult
+	if err == nil {
+		res, err = w.send(ctx, rec)
+	}
+	p.Result = res
+	if err != nil {
+		p.LastError = err.Error()
+	}
+	if res == nil {
+		// Never sent, e.g. over the fee cap, so it can be tried again with
+		// the next signature
+		p.Status = CosignCollecting
+	}
+
+	w.cosignMu.Lock()
+	putErr := w.putPendingTx(p)
+	w.cosignMu.Unlock()
+	if putErr != nil {
+		return putErr
+	}
+	return err
+}
+
+type verifiedSignature struct {
+	signer    string
+	weight    int32
+	signature xdr.DecoratedSignature
+}
+
+// verifySignatures returns the signatures made by signers over hash, one
+// per signer. Account keys sign the hash, hash-x signers reveal the
+// preimage of their key.
+func verifySignatures(hash [32]byte, signatures []xdr.DecoratedSignature, signers []AccountSigner) []verifiedSignature {
+	var valid []verifiedSignature
+	for _, s := range signers {
+		for _, sig := range signatures {
+			if signs(s.Key, hash, sig) {
+				valid = append(valid, verifiedSignature{signer: s.Key, weight: s.Weight, signature: sig})
+				break
+			}
+		}
+	}
+	return valid
+}
+
+func signs(key string, hash [32]byte, sig xdr.DecoratedSignature) bool {
+	version, err := strkey.Version(key)
+	if err != nil {
+		return false
+	}
+
+	switch version {
+	case strkey.VersionByteAccountID:
+		kp, err := keypair.ParseAddress(key)
+		if err != nil {
+			return false
+		}
+		return kp.Hint() == [4]byte(sig.Hint) && kp.Verify(hash[:], sig.Signature) == nil
+	case strkey.VersionByteHashX:
+		raw, err := strkey.Decode(version, key)
+		if err != nil || len(raw) != 32 {
+			return false
+		}
+		preimage := sha256.Sum256(sig.Signature)
+		return bytes.Equal(raw[28:], sig.Hint[:]) && bytes.Equal(raw, preimage[:])
+	}
+	return false
+}
+
+func withSignatures(tx *txnbuild.Transaction, valid []verifiedSignature) (*txnbuild.Transaction, error) {
+	tx, err := tx.ClearSignatures()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range valid {
+		if tx, err = tx.AddSignatureDecorated(v.signature); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func decodeTx(envelopeXDR string) (*txnbuild.Transaction, error) {
+	generic, err := txnbuild.TransactionFromXDR(envelopeXDR)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding transaction: %w", err)
+	}
+	tx, ok := generic.Transaction()
+	if !ok {
+		return nil, errors.New("envelope is not a regular transaction")
+	}
+	return tx, nil
+}
+
+// UnsignedXDR is the proposal without signatures, for signers to sign
+// with their own wallet.
+func (p *PendingTx) UnsignedXDR() (string, error) {
+	tx, err := decodeTx(p.EnvelopeXDR)
+	if err != nil {
+		return "", err
+	}
+	if tx, err = tx.ClearSignatures(); err != nil {
+		return "", err
+	}
+	return tx.Base64()
+}
+
+// DiscardPending drops a proposal that is still collecting signatures.
+func (w *Wallet) DiscardPending(id string) (*PendingTx, error) {
+	w.cosignMu.Lock()
+	defer w.cosignMu.Unlock()
+
+	p, err := w.PendingTx(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != CosignCollecting {
+		return p, fmt.Errorf("%w: transaction is %s", ErrNotCollecting, p.Status)
+	}
+	p.Status = CosignDiscarded
+	return p, w.putPendingTx(p)
+}
+
+func (w *Wallet) putPendingTx(p *PendingTx) error {
+	p.UpdatedAt = time.Now()
+	return w.cosignStore.Put(p.ID, *p)
+}
+
+func (w *Wallet) PendingTx(id string) (*PendingTx, error) {
+	p, found, err := w.cosignStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrPendingNotFound
+	}
+	return &p, nil
+}
+
+// PendingTxs lists the proposals address can sign or that are for its
+// account.
+func (w *Wallet) PendingTxs(address string) ([]PendingTx, error) {
+	all, err := w.cosignStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []PendingTx{}
+	for _, p := range all {
+		if p.Source == address || p.CanSign(address) {
+			pending = append(pending, p)
+		}
+	}
+	return pending, nil
+}
+
+// SweepPendingTxs expires proposals until ctx is canceled: those past their
+// window, and those whose sequence number another transaction used.
+func (w *Wallet) SweepPendingTxs(ctx context.Context) {
+	ticker := time.NewTicker(cosignSweep)
+	defer ticker.Stop()
+
+	for {
+		if err := w.sweepPendingTxs(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Error sweeping pending transactions: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepPendingTxs looks proposals up without cosignMu, and only takes it
+// to expire one that is still collecting.
+func (w *Wallet) sweepPendingTxs(ctx context.Context) error {
+	all, err := w.cosignStore.List()
+	if err != nil {
+		return err
+	}
+
+	sequences := map[string]int64{}
+	for _, p := range all {
+		if p.Status != CosignCollecting {
+			continue
+		}
+
+		if !time.Now().Before(p.ExpiresAt) {
+			if err := w.expirePending(p.ID, "collection window passed"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sequence, ok := sequences[p.Source]
+		if !ok {
+			account, err := w.horizon(ctx).AccountDetail(hClient.AccountRequest{AccountID: p.Source})
+			if err != nil {
+				return fmt.Errorf("error fetching account details: %w", err)
+			}
+			sequence = account.Sequence
+			sequences[p.Source] = sequence
+		}
+		tx, err := decodeTx(p.EnvelopeXDR)
+		if err != nil {
+			return err
+		}
+		if sequence >= tx.SourceAccount().Sequence {
+			if err := w.expirePending(p.ID, "sequence number was consumed by another transaction"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Wallet) expirePending(id, reason string) error {
+	w.cosignMu.Lock()
+	defer w.cosignMu.Unlock()
+
+	// It may have been signed or discarded since it was listed
+	p, err := w.PendingTx(id)
+	if err != nil || p.Status != CosignCollecting {
+		return err
+	}
+	p.Status = CosignExpired
+	p.LastError = reason
+	return w.putPendingTx(p)
+}
@@ -0,0 +1,322 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"pi/store"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+const testPassphrase = "Test SDF Network ; September 2015"
+
+func testTx(t *testing.T, source string) *txnbuild.Transaction {
+	t.Helper()
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source, Sequence: 1},
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 10}},
+		BaseFee:              txnbuild.MinBaseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func sign(t *testing.T, kp *keypair.Full, hash [32]byte) xdr.DecoratedSignature {
+	t.Helper()
+	sig, err := kp.SignDecorated(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// hashXSigner returns a hash-x signer key and the signature revealing its
+// preimage.
+func hashXSigner(t *testing.T, preimage []byte) (string, xdr.DecoratedSignature) {
+	t.Helper()
+	h := sha256.Sum256(preimage)
+	key, err := strkey.Encode(strkey.VersionByteHashX, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, xdr.DecoratedSignature{Hint: xdr.SignatureHint(h[28:]), Signature: preimage}
+}
+
+func TestSigns(t *testing.T) {
+	kp := keypair.MustRandom()
+	other := keypair.MustRandom()
+	hash := sha256.Sum256([]byte("transaction"))
+	otherHash := sha256.Sum256([]byte("another transaction"))
+
+	valid := sign(t, kp, hash)
+	wrongHint := valid
+	wrongHint.Hint = xdr.SignatureHint(other.Hint())
+	stolenHint := sign(t, other, hash)
+	stolenHint.Hint = xdr.SignatureHint(kp.Hint())
+
+	hashXKey, preimage := hashXSigner(t, []byte("secret"))
+	_, wrongPreimage := hashXSigner(t, []byte("guess"))
+	wrongPreimage.Hint = preimage.Hint
+	preimageWrongHint := preimage
+	preimageWrongHint.Hint = xdr.SignatureHint{0, 0, 0, 0}
+
+	h := sha256.Sum256([]byte("secret"))
+	preAuth, err := strkey.Encode(strkey.VersionByteHashTx, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		sig  xdr.DecoratedSignature
+		want bool
+	}{
+		{"ed25519", kp.Address(), valid, true},
+		{"ed25519 over another hash", kp.Address(), sign(t, kp, otherHash), false},
+		{"ed25519 by another key", kp.Address(), sign(t, other, hash), false},
+		{"ed25519 with the wrong hint", kp.Address(), wrongHint, false},
+		{"ed25519 by another key with the right hint", kp.Address(), stolenHint, false},
+		{"hash-x preimage", hashXKey, preimage, true},
+		{"hash-x wrong preimage", hashXKey, wrongPreimage, false},
+		{"hash-x with the wrong hint", hashXKey, preimageWrongHint, false},
+		{"hash-x given a signature", hashXKey, valid, false},
+		{"pre-auth tx", preAuth, preimage, false},
+		{"invalid key", "GNOTAKEY", valid, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signs(tt.key, hash, tt.sig); got != tt.want {
+				t.Errorf("signs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	a := keypair.MustRandom()
+	b := keypair.MustRandom()
+	stranger := keypair.MustRandom()
+	hashXKey, preimage := hashXSigner(t, []byte("secret"))
+	hash := sha256.Sum256([]byte("transaction"))
+
+	signers := []AccountSigner{
+		{Key: a.Address(), Weight: 1},
+		{Key: b.Address(), Weight: 2},
+		{Key: hashXKey, Weight: 3},
+	}
+
+	tests := []struct {
+		name       string
+		signatures []xdr.DecoratedSignature
+		want       []string
+	}{
+		{"none", nil, nil},
+		{"stranger only", []xdr.DecoratedSignature{sign(t, stranger, hash)}, nil},
+		{"in signer order", []xdr.DecoratedSignature{sign(t, b, hash), sign(t, a, hash)}, []string{a.Address(), b.Address()}},
+		{"one per signer", []xdr.DecoratedSignature{sign(t, a, hash), sign(t, a, hash)}, []string{a.Address()}},
+		{"stranger dropped", []xdr.DecoratedSignature{sign(t, stranger, hash), sign(t, b, hash)}, []string{b.Address()}},
+		{"hash-x preimage", []xdr.DecoratedSignature{preimage, sign(t, a, hash)}, []string{a.Address(), hashXKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := verifySignatures(hash, tt.signatures, signers)
+			if len(valid) != len(tt.want) {
+				t.Fatalf("got %d signatures, want %d", len(valid), len(tt.want))
+			}
+			for i, v := range valid {
+				if v.signer != tt.want[i] {
+					t.Errorf("signature %d from %s, want %s", i, v.signer, tt.want[i])
+				}
+				for _, s := range signers {
+					if s.Key == v.signer && s.Weight != v.weight {
+						t.Errorf("signature %d weighs %d, want %d", i, v.weight, s.Weight)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	source := keypair.MustRandom()
+	a := keypair.MustRandom()
+	b := keypair.MustRandom()
+	c := keypair.MustRandom()
+	stranger := keypair.MustRandom()
+
+	signers := []AccountSigner{
+		{Key: a.Address(), Weight: 1},
+		{Key: b.Address(), Weight: 2},
+		{Key: c.Address(), Weight: 2},
+	}
+
+	tests := []struct {
+		name     string
+		required int32
+		signedBy []*keypair.Full
+		// want lists who signs the transaction sent, heaviest first, nil if
+		// it isn't sent
+		want       []*keypair.Full
+		wantWeight int32
+		wantSigs   int
+	}{
+		{
+			name:       "below threshold",
+			required:   3,
+			signedBy:   []*keypair.Full{a},
+			wantWeight: 1,
+			wantSigs:   1,
+		},
+		{
+			name:       "stranger doesn't count",
+			required:   2,
+			signedBy:   []*keypair.Full{a, stranger},
+			wantWeight: 1,
+			wantSigs:   1,
+		},
+		{
+			name:       "exactly met",
+			required:   3,
+			signedBy:   []*keypair.Full{a, b},
+			want:       []*keypair.Full{b, a},
+			wantWeight: 3,
+		},
+		{
+			name:       "heaviest alone is enough",
+			required:   2,
+			signedBy:   []*keypair.Full{a, b, c},
+			want:       []*keypair.Full{b},
+			wantWeight: 5,
+		},
+		{
+			name:       "lightest left out",
+			required:   4,
+			signedBy:   []*keypair.Full{a, b, c},
+			want:       []*keypair.Full{b, c},
+			wantWeight: 5,
+		},
+		{
+			name:       "stranger not sent",
+			required:   1,
+			signedBy:   []*keypair.Full{stranger, a},
+			want:       []*keypair.Full{a},
+			wantWeight: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cosignStore, err := store.Open[PendingTx](t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &Wallet{networkPassphrase: testPassphrase, cosignStore: cosignStore}
+
+			tx, err := testTx(t, source.Address()).Sign(testPassphrase, tt.signedBy...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash, err := tx.HashHex(testPassphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			envelope, err := tx.Base64()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := &PendingTx{
+				ID:          hash,
+				Source:      source.Address(),
+				EnvelopeXDR: envelope,
+				Required:    tt.required,
+				Signers:     signers,
+				Status:      CosignCollecting,
+			}
+			sent, err := w.collect(p, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Weight != tt.wantWeight {
+				t.Errorf("weight = %d, want %d", p.Weight, tt.wantWeight)
+			}
+
+			if tt.want == nil {
+				if sent != nil {
+					t.Fatal("transaction sent below its threshold")
+				}
+				if p.Status != CosignCollecting {
+					t.Errorf("status = %s, want %s", p.Status, CosignCollecting)
+				}
+				kept, err := decodeTx(p.EnvelopeXDR)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := len(kept.Signatures()); got != tt.wantSigs {
+					t.Errorf("kept %d signatures, want %d", got, tt.wantSigs)
+				}
+				return
+			}
+
+			if sent == nil {
+				t.Fatal("transaction not sent once its threshold was met")
+			}
+			if p.Status != CosignSubmitted {
+				t.Errorf("status = %s, want %s", p.Status, CosignSubmitted)
+			}
+			// Any signature beyond these fails with tx_bad_auth_extra
+			sigs := sent.Signatures()
+			if len(sigs) != len(tt.want) {
+				t.Fatalf("sent %d signatures, want %d", len(sigs), len(tt.want))
+			}
+			for i, kp := range tt.want {
+				if [4]byte(sigs[i].Hint) != kp.Hint() {
+					t.Errorf("signature %d is not from %s", i, kp.Address())
+				}
+			}
+			if stored, err := w.PendingTx(p.ID); err != nil || stored.EnvelopeXDR != p.EnvelopeXDR {
+				t.Errorf("stored proposal doesn't match, err %v", err)
+			}
+		})
+	}
+}
+
+func TestRequiredLevel(t *testing.T) {
+	dest := keypair.MustRandom().Address()
+	payment := &txnbuild.Payment{Destination: dest, Amount: "1", Asset: txnbuild.NativeAsset{}}
+	bump := &txnbuild.BumpSequence{BumpTo: 10}
+	weight := txnbuild.Threshold(2)
+
+	tests := []struct {
+		name string
+		ops  []txnbuild.Operation
+		want string
+	}{
+		{"bump sequence", []txnbuild.Operation{bump}, ThresholdLow},
+		{"inflation", []txnbuild.Operation{&txnbuild.Inflation{}}, ThresholdLow},
+		{"payment", []txnbuild.Operation{payment}, ThresholdMedium},
+		{"set options", []txnbuild.Operation{&txnbuild.SetOptions{HomeDomain: txnbuild.NewHomeDomain("example.com")}}, ThresholdMedium},
+		{"set options signers", []txnbuild.Operation{&txnbuild.SetOptions{Signer: &txnbuild.Signer{Address: dest, Weight: 1}}}, ThresholdHigh},
+		{"set options thresholds", []txnbuild.Operation{&txnbuild.SetOptions{HighThreshold: &weight}}, ThresholdHigh},
+		{"account merge", []txnbuild.Operation{&txnbuild.AccountMerge{Destination: dest}}, ThresholdHigh},
+		{"highest wins", []txnbuild.Operation{bump, payment}, ThresholdMedium},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requiredLevel(tt.ops); got != tt.want {
+				t.Errorf("requiredLevel = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
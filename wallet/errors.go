@@ -3,257 +3,113 @@ package wallet
 import (
 	"errors"
 	"fmt"
-	"strconv"
-	"time"
+	"reflect"
+	"strings"
 
-	"github.com/stellar/go/keypair"
-	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/go/xdr"
 )
 
-var ErrUnAuthorized = errors.New("unauthorized")
-
-func getTxErrorFromResultXdr(resultXdr string) error {
-	var txResult xdr.TransactionResult
-	if err := xdr.SafeUnmarshalBase64(resultXdr, &txResult); err != nil {
-		return fmt.Errorf("failed to decode result XDR: %w", err)
-	}
-
-	// Transaction-level error
-	if txResult.Result.Code != xdr.TransactionResultCodeTxSuccess {
-		return fmt.Errorf("transaction failed with code: %s", txResult.Result.Code.String())
-	}
-
-	if txResult.Result.Results == nil {
-		return fmt.Errorf("transaction succeeded but no operation results returned")
-	}
-
-	for i, opResult := range *txResult.Result.Results {
-		switch opResult.Tr.Type {
-		case xdr.OperationTypePayment:
-			if opResult.Tr.PaymentResult == nil {
-				return fmt.Errorf("operation %d: missing payment result", i)
-			}
-			code := opResult.Tr.PaymentResult.Code
-			if code != xdr.PaymentResultCodePaymentSuccess {
-				return fmt.Errorf("operation %d failed: %s", i, code.String())
-			}
-
-		case xdr.OperationTypeClaimClaimableBalance:
-			if opResult.Tr.ClaimClaimableBalanceResult == nil {
-				return fmt.Errorf("operation %d: missing claim claimable balance result", i)
-			}
-			code := opResult.Tr.ClaimClaimableBalanceResult.Code
-			if code != xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceSuccess {
-				return fmt.Errorf("operation %d failed: %s", i, code.String())
-			}
-
-		default:
-			return fmt.Errorf("operation %d has unsupported type: %s", i, opResult.Tr.Type.String())
-		}
-	}
+var (
+	ErrUnAuthorized         = errors.New("unauthorized")
+	ErrNetworkMismatch      = errors.New("network passphrase mismatch")
+	ErrBaseReserveNotFound  = errors.New("base reserve has not been loaded")
+	ErrTxPending            = errors.New("transaction is still pending")
+	ErrTxDropped            = errors.New("transaction can no longer be applied")
+	ErrTxNotFound           = errors.New("transaction not found")
+	ErrShuttingDown         = errors.New("wallet is shutting down, no new transactions are sent")
+	ErrFeeCapExceeded       = errors.New("fee cap exceeded")
+	ErrFeeBumpDisabled      = errors.New("fee bumps are disabled, no fee account is configured")
+	ErrInvalidAddress       = errors.New("invalid address")
+	ErrInvalidMemo          = errors.New("invalid memo")
+	ErrMemoRequired         = errors.New("destination requires a memo")
+	ErrDestinationNotFunded = errors.New("destination account does not exist")
+	ErrInsufficientBalance  = errors.New("insufficient available balance")
+	ErrInvalidPredicate     = errors.New("invalid claim predicate")
+	ErrScheduleNotFound     = errors.New("schedule not found")
+	ErrVestingBusy          = errors.New("schedule is already being updated")
+	ErrNothingToReclaim     = errors.New("no balances can be reclaimed yet")
+	ErrBalanceIDMismatch    = errors.New("balance was not created by transaction")
+	ErrBatchInvalid         = errors.New("batch is invalid")
+	ErrBatchNotFound        = errors.New("batch not found")
+	ErrBatchRunning         = errors.New("batch is already running")
+	ErrKeystoreDisabled     = errors.New("keystore is disabled, no keystore passphrase is configured")
+	ErrKeyNotFound          = errors.New("no stored key for account")
+	ErrInvalidSchedule      = errors.New("invalid schedule")
+	ErrRecurringNotFound    = errors.New("recurring payment not found")
+	ErrRecurringExhausted   = errors.New("recurring payment has reached its spending cap")
+	ErrRequestNotFound      = errors.New("payment request not found")
+	ErrLockout              = errors.New("change would lock the account out")
+	ErrThresholdNotMet      = errors.New("signatures do not meet the threshold")
+	ErrPendingNotFound      = errors.New("pending transaction not found")
+	ErrNotCollecting        = errors.New("transaction is no longer collecting signatures")
+	ErrInvalidSignature     = errors.New("invalid signature")
+)
 
-	return nil
+// TxFailedError is returned when the network rejects or fails a transaction.
+// Codes use Horizon's snake_case names, e.g. tx_failed and op_underfunded.
+type TxFailedError struct {
+	Hash           string
+	ResultCode     string
+	OperationCodes []string
 }
 
-func (w *Wallet) Transfer(kp *keypair.Full, amountStr string, address string) error {
-	// Parse requested amount first
-	requestedAmount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		return fmt.Errorf("invalid amount: %w", err)
-	}
-
-	// Check if amount is too small or negative
-	if requestedAmount <= 0.001 {
-		return fmt.Errorf("amount too small to transfer: %.7f PI", requestedAmount)
-	}
-
-	w.GetBaseReserve()
-	baseReserve := w.baseReserve
-
-	// Get account details
-	account, err := w.GetAccount(kp)
-	if err != nil {
-		return fmt.Errorf("error getting account: %w", err)
-	}
-
-	// Get actual native (PI) balance
-	var nativeBalance float64
-	for _, bal := range account.Balances {
-		if bal.Asset.Type == "native" {
-			nativeBalance, err = strconv.ParseFloat(bal.Balance, 64)
-			if err != nil {
-				return fmt.Errorf("invalid balance format: %w", err)
-			}
-			break
+func (e *TxFailedError) Error() string {
+	var failed []string
+	for i, code := range e.OperationCodes {
+		if code != "op_success" {
+			failed = append(failed, fmt.Sprintf("operation %d: %s", i, code))
 		}
 	}
 
-	// Calculate minimum required balance
-	minBalance := baseReserve * float64(2+account.SubentryCount)
-
-	// Available balance = total - reserve - transaction fee
-	available := nativeBalance - minBalance - 0.01
-	if available <= 0 {
-		return fmt.Errorf("insufficient available balance")
+	if len(failed) == 0 {
+		return fmt.Sprintf("transaction failed with code: %s", e.ResultCode)
 	}
-
-	// Use the smaller of requested amount or available balance
-	transferAmount := requestedAmount
-	if transferAmount > available {
-		transferAmount = available - 0.001 // Leave small buffer
-	}
-
-	// Final check
-	if transferAmount <= 0 {
-		return fmt.Errorf("insufficient balance for transfer")
-	}
-
-	// Build payment operation
-	paymentOp := txnbuild.Payment{
-		Destination: address,
-		Amount:      strconv.FormatFloat(transferAmount, 'f', 7, 64),
-		Asset:       txnbuild.NativeAsset{},
-	}
-
-	// Build transaction
-	txParams := txnbuild.TransactionParams{
-		SourceAccount:        &account,
-		IncrementSequenceNum: true,
-		Operations:           []txnbuild.Operation{&paymentOp},
-		BaseFee:              txnbuild.MinBaseFee,
-		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
-	}
-
-	tx, err := txnbuild.NewTransaction(txParams)
-	if err != nil {
-		return fmt.Errorf("error building transaction: %w", err)
-	}
-
-	// Sign and submit
-	signedTx, err := tx.Sign(w.networkPassphrase, kp)
-	if err != nil {
-		return fmt.Errorf("error signing transaction: %w", err)
-	}
-
-	resp, err := w.client.SubmitTransaction(signedTx)
-	if err != nil {
-		return fmt.Errorf("error submitting transaction: %w", err)
-	}
-
-	if !resp.Successful {
-		return getTxErrorFromResultXdr(resp.ResultXdr)
-	}
-
-	fmt.Printf("Transfer successful: %.7f PI - Hash: %s\n", transferAmount, resp.Hash)
-	return nil
+	return fmt.Sprintf("transaction failed with code: %s (%s)", e.ResultCode, strings.Join(failed, ", "))
 }
 
-func (w *Wallet) WithdrawClaimableBalance(kp *keypair.Full, amountStr, balanceID, address string) (string, float64, error) {
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		return "", 0, fmt.Errorf("error formatting amount: %s", err.Error())
+// resultCodes extracts the transaction and operation result codes from a
+// base64 TransactionResult.
+func resultCodes(resultXdr string) (string, []string) {
+	var txResult xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(resultXdr, &txResult); err != nil {
+		return "", nil
 	}
-	amount = amount - 0.01
 
-	hash, err := w.ClaimAndWithdraw(kp, amount, balanceID, address)
-	if err != nil {
-		return "", amount, fmt.Errorf("error claiming and withdrawing: %v", err)
+	var opCodes []string
+	if opResults, ok := txResult.OperationResults(); ok {
+		for _, opResult := range opResults {
+			opCodes = append(opCodes, operationResultCode(opResult))
+		}
 	}
 
-	return hash, amount, nil
+	return resultCodeName(txResult.Result.Code), opCodes
 }
 
-func (w *Wallet) ClaimAndWithdraw(kp *keypair.Full, amount float64, balanceID, address string) (string, error) {
-	account, err := w.GetAccount(kp)
-	if err != nil {
-		return "", err
-	}
-
-	claimOp := txnbuild.ClaimClaimableBalance{
-		BalanceID: balanceID,
+// operationResultCode names an operation result the way Horizon does, e.g.
+// PaymentResultCodePaymentNoDestination becomes op_no_destination.
+func operationResultCode(res xdr.OperationResult) string {
+	if res.Code != xdr.OperationResultCodeOpInner || res.Tr == nil {
+		return snakeCase(strings.TrimPrefix(res.Code.String(), "OperationResultCode"))
 	}
 
-	paymentOp := txnbuild.Payment{
-		Destination: address,
-		Amount:      strconv.FormatFloat(amount, 'f', -1, 64),
-		Asset:       txnbuild.NativeAsset{},
-	}
-
-	txParams := txnbuild.TransactionParams{
-		SourceAccount:        &account,
-		IncrementSequenceNum: true,
-		Operations:           []txnbuild.Operation{&claimOp, &paymentOp},
-		BaseFee:              1_000_000,
-		Preconditions: txnbuild.Preconditions{
-			TimeBounds: txnbuild.NewInfiniteTimeout(),
-		},
-	}
-
-	tx, err := txnbuild.NewTransaction(txParams)
-	if err != nil {
-		return "", fmt.Errorf("error building transaction: %v", err)
-	}
-
-	signedTx, err := tx.Sign(w.networkPassphrase, kp)
-	if err != nil {
-		return "", fmt.Errorf("error signing transaction: %v", err)
-	}
+	// Every arm of the result union is a pointer to a struct with a Code
+	tr := reflect.ValueOf(*res.Tr)
+	for i := 0; i < tr.NumField(); i++ {
+		field := tr.Field(i)
+		if field.Kind() != reflect.Pointer || field.IsNil() || field.Elem().Kind() != reflect.Struct {
+			continue
+		}
 
-	resp, err := w.client.SubmitTransaction(signedTx)
-	if err != nil {
-		return "", fmt.Errorf("error submitting transaction: %v", err)
-	}
+		code, ok := field.Elem().FieldByName("Code").Interface().(fmt.Stringer)
+		if !ok {
+			continue
+		}
 
-	if !resp.Successful {
-		return "", fmt.Errorf("transaction failed")
+		opName := strings.TrimSuffix(field.Elem().Type().Name(), "Result")
+		name := strings.TrimPrefix(code.String(), opName+"ResultCode")
+		name = strings.TrimPrefix(name, opName)
+		return "op_" + snakeCase(name)
 	}
 
-	return resp.Hash, nil
+	return "op_inner"
 }
-
-func (w *Wallet) CreateClaimable(kp *keypair.Full, recipientAddress string, amount float64) (string, error) {
-	senderAccount, err := w.GetAccount(kp)
-	if err != nil {
-		return "", err
-	}
-
-	t := time.Now().Add(10 * time.Minute)
-	claimant := txnbuild.Claimant{
-		Destination: recipientAddress,
-		Predicate:   txnbuild.NotPredicate(txnbuild.BeforeAbsoluteTimePredicate(t.Unix())),
-	}
-
-	createOp := txnbuild.CreateClaimableBalance{
-		Asset:        txnbuild.NativeAsset{},
-		Amount:       fmt.Sprintf("%.2f", amount),
-		Destinations: []txnbuild.Claimant{claimant},
-	}
-
-	txParams := txnbuild.TransactionParams{
-		SourceAccount:        &senderAccount,
-		IncrementSequenceNum: true,
-		Operations:           []txnbuild.Operation{&createOp},
-		BaseFee:              1_000_000, //txnbuild.MinBaseFee,
-		Preconditions: txnbuild.Preconditions{
-			TimeBounds: txnbuild.NewInfiniteTimeout(),
-		},
-	}
-
-	tx, err := txnbuild.NewTransaction(txParams)
-	if err != nil {
-		return "", fmt.Errorf("error building transaction: %v", err)
-	}
-
-	signedTx, err := tx.Sign(w.networkPassphrase, kp)
-	if err != nil {
-		return "", fmt.Errorf("error signing transaction: %v", err)
-	}
-
-	resp, err := w.client.SubmitTransaction(signedTx)
-	if err != nil {
-		return "", fmt.Errorf("error submitting transaction: %v", err)
-	}
-
-	return resp.Hash, nil
-}
\ No newline at end of file
@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+// ReclaimableBalance is a claimable balance the caller created and is
+// itself a claimant of, typically through ReclaimableBy.
+type ReclaimableBalance struct {
+	ID     string `json:"id"`
+	Asset  string `json:"asset"`
+	Amount string `json:"amount"`
+	// Claimants other than the caller, who may still claim it first
+	Recipients []string `json:"recipients"`
+	// ReclaimableAt is set for the usual "not before expiry" predicate
+	ReclaimableAt *time.Time `json:"reclaimable_at,omitempty"`
+	Reclaimable   bool       `json:"reclaimable"`
+}
+
+// ReclaimableBalances lists the balances kp created and can claim back,
+// noting which of them it can claim right now.
+func (w *Wallet) ReclaimableBalances(ctx context.Context, kp *keypair.Full) ([]ReclaimableBalance, error) {
+	address := kp.Address()
+	client := w.horizon(ctx)
+
+	// The creator of a balance sponsors its reserve
+	req := hClient.ClaimableBalanceRequest{
+		Sponsor:  address,
+		Claimant: address,
+		Limit:    200,
+	}
+
+	balances := []ReclaimableBalance{}
+	now := time.Now()
+	for {
+		page, err := client.ClaimableBalances(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching claimable balances: %w", err)
+		}
+
+		for _, b := range page.Embedded.Records {
+			rb := ReclaimableBalance{ID: b.BalanceID, Asset: b.Asset, Amount: b.Amount, Recipients: []string{}}
+			for _, c := range b.Claimants {
+				if c.Destination != address {
+					rb.Recipients = append(rb.Recipients, c.Destination)
+					continue
+				}
+
+				predicate, err := PredicateFromXDR(c.Predicate)
+				if err != nil {
+					return nil, fmt.Errorf("error reading predicate of %s: %w", b.BalanceID, err)
+				}
+				rb.Reclaimable = predicate.Holds(now)
+				if predicate.Not != nil && predicate.Not.AbsBefore != nil {
+					rb.ReclaimableAt = predicate.Not.AbsBefore
+				}
+			}
+			balances = append(balances, rb)
+		}
+
+		if len(page.Embedded.Records) < int(req.Limit) {
+			break
+		}
+		req.Cursor = page.Embedded.Records[len(page.Embedded.Records)-1].PT
+	}
+
+	return balances, nil
+}
+
+// Reclaim claims back balances kp created. Without IDs it reclaims every
+// balance that is reclaimable now. Balances are claimed in batches of up
+// to 100 per transaction, the result of each is returned.
+func (w *Wallet) Reclaim(ctx context.Context, kp *keypair.Full, balanceIDs []string, profile FeeProfile) ([]*TxResult, error) {
+	if len(balanceIDs) == 0 {
+		balances, err := w.ReclaimableBalances(ctx, kp)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances {
+			if b.Reclaimable {
+				balanceIDs = append(balanceIDs, b.ID)
+			}
+		}
+		if len(balanceIDs) == 0 {
+			return nil, ErrNothingToReclaim
+		}
+	}
+
+	var results []*TxResult
+	for start := 0; start < len(balanceIDs); start += maxOpsPerTx {
+		ids := balanceIDs[start:min(start+maxOpsPerTx, len(balanceIDs))]
+
+		ops := make([]txnbuild.Operation, len(ids))
+		for i, id := range ids {
+			ops[i] = &txnbuild.ClaimClaimableBalance{BalanceID: id}
+		}
+
+		res, err := w.Run(ctx, &TxJob{
+			Label:      "reclaim claimable balance",
+			Source:     kp,
+			Operations: ops,
+			FeeProfile: profile,
+		})
+		if res != nil {
+			results = append(results, res)
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/txnbuild"
+)
+
+// preconditions bounds the job's transaction in time, and in ledgers when a
+// ledger window is configured, so it can't land long after it was built.
+func (w *Wallet) preconditions(ctx context.Context, job *TxJob) (txnbuild.Preconditions, error) {
+	timeout := job.Timeout
+	if timeout == 0 {
+		timeout = w.txTimeout
+	}
+
+	if job.LedgerBounds == nil && !job.NoLedgerWindow && w.ledgerWindow > 0 {
+		root, err := w.horizon(ctx).Root()
+		if err != nil {
+			return txnbuild.Preconditions{}, fmt.Errorf("error fetching latest ledger: %w", err)
+		}
+		job.LedgerBounds = &txnbuild.LedgerBounds{
+			MaxLedger: uint32(root.HorizonSequence) + w.ledgerWindow,
+		}
+	}
+
+	return txnbuild.Preconditions{
+		TimeBounds:        txnbuild.NewTimeout(int64(timeout.Seconds())),
+		LedgerBounds:      job.LedgerBounds,
+		MinSequenceNumber: job.MinSequence,
+	}, nil
+}
+
+// deadReason explains why a transaction Horizon hasn't seen can never be
+// applied, or returns "" if it still might be.
+func (w *Wallet) deadReason(client *hClient.Client, rec *TxRecord) (string, error) {
+	account, err := client.AccountDetail(hClient.AccountRequest{AccountID: rec.Source})
+	if err != nil {
+		return "", fmt.Errorf("error fetching account details: %v", err)
+	}
+	if account.Sequence >= rec.Sequence {
+		return "sequence number was consumed by another transaction", nil
+	}
+
+	if rec.MaxTime == 0 && rec.MaxLedger == 0 {
+		return "", nil
+	}
+
+	root, err := client.Root()
+	if err != nil {
+		return "", fmt.Errorf("error fetching latest ledger: %w", err)
+	}
+
+	// Every later ledger closes after the latest one, so bounds it already
+	// passed can't be met by any of them
+	if rec.MaxTime != 0 && root.HorizonLatestClosedAt.Unix() > rec.MaxTime {
+		rec.ResultCode = "tx_too_late"
+		return "time bounds expired", nil
+	}
+	if rec.MaxLedger != 0 && uint32(root.HorizonSequence)+1 >= rec.MaxLedger {
+		rec.ResultCode = "tx_too_late"
+		return "ledger bounds expired", nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/txnbuild"
+)
+
+// feeBumpMultiplier is how far a fee bump must outbid the fee rate of the
+// transaction it replaces for Stellar Core to swap it in its queue.
+const feeBumpMultiplier = 10
+
+// FeeBump wraps a pending transaction in a fee-bump transaction paid by the
+// fee account and submits it. The fee is priced by profile, but always high
+// enough to replace the original, and must stay within the fee caps.
+func (w *Wallet) FeeBump(ctx context.Context, hash string, profile FeeProfile) (res *TxResult, err error) {
+	if w.feeAccount == nil {
+		return nil, ErrFeeBumpDisabled
+	}
+
+	job := &TxJob{Label: "fee bump", Source: w.feeAccount}
+	defer func() {
+		for _, hook := range w.pipeline.Audit {
+			hook(ctx, job, res, err)
+		}
+	}()
+
+	rec, err := w.TransactionStatus(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if rec.InnerHash != "" {
+		// Bump the original again rather than wrapping a fee bump
+		rec, err = w.TransactionStatus(ctx, rec.InnerHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rec.Terminal() {
+		return nil, fmt.Errorf("transaction %s is already %s", rec.Hash, rec.Status)
+	}
+
+	generic, err := txnbuild.TransactionFromXDR(rec.EnvelopeXDR)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding transaction: %w", err)
+	}
+	inner, ok := generic.Transaction()
+	if !ok {
+		return nil, errors.New("stored envelope is not a regular transaction")
+	}
+
+	fee, err := w.EstimateFee(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+	fee = max(fee, inner.BaseFee()*feeBumpMultiplier)
+
+	// The fee bump itself counts as one more operation
+	if maxFee := fee * int64(len(inner.Operations())+1); maxFee > w.maxFee {
+		return nil, fmt.Errorf("%w: the fee bump needs %d stroops, the per transaction limit is %d", ErrFeeCapExceeded, maxFee, w.maxFee)
+	}
+
+	bump, err := txnbuild.NewFeeBumpTransaction(txnbuild.FeeBumpTransactionParams{
+		Inner:      inner,
+		FeeAccount: w.feeAccount.Address(),
+		BaseFee:    fee,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error building fee bump: %w", err)
+	}
+	bump, err = bump.Sign(w.networkPassphrase, w.feeAccount)
+	if err != nil {
+		return nil, fmt.Errorf("error signing fee bump: %w", err)
+	}
+
+	bumpHash, err := bump.HashHex(w.networkPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing fee bump: %w", err)
+	}
+	envelope, err := bump.Base64()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding fee bump: %w", err)
+	}
+
+	// The same fee yields the same fee bump, which may already be in flight
+	if prev, found, err := w.txStore.Get(bumpHash); err != nil || found {
+		if err != nil {
+			return nil, err
+		}
+		if err := w.refreshTx(ctx, &prev); err != nil {
+			return nil, err
+		}
+		return prev.result(), prev.err()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The fee bump is valid exactly as long as its inner transaction, so
+	// it is tracked against the inner source's sequence and bounds
+	return w.send(ctx, &TxRecord{
+		Hash:        bumpHash,
+		Label:       job.Label,
+		Source:      rec.Source,
+		Sequence:    rec.Sequence,
+		EnvelopeXDR: envelope,
+		MaxFee:      bump.MaxFee(),
+		MaxTime:     rec.MaxTime,
+		MaxLedger:   rec.MaxLedger,
+		InnerHash:   rec.Hash,
+		FeeAccount:  w.feeAccount.Address(),
+		Status:      TxPending,
+		CreatedAt:   time.Now(),
+	})
+}
@@ -0,0 +1,204 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+)
+
+// feeStatsTTL is how long fee stats are reused. Ledgers close roughly every
+// five seconds, so fresher stats rarely differ.
+const feeStatsTTL = 5 * time.Second
+
+type FeeProfile string
+
+const (
+	// FeeLow gets included when the network is quiet
+	FeeLow FeeProfile = "low"
+	// FeeNormal pays what the median transaction paid recently
+	FeeNormal FeeProfile = "normal"
+	// FeeHigh outbids most other senders, for time critical transactions
+	FeeHigh FeeProfile = "high"
+)
+
+func ParseFeeProfile(s string) (FeeProfile, error) {
+	switch p := FeeProfile(s); p {
+	case FeeLow, FeeNormal, FeeHigh:
+		return p, nil
+	case "":
+		return FeeNormal, nil
+	}
+	return "", fmt.Errorf("unknown fee profile %q, expected low, normal or high", s)
+}
+
+// FeeDay is the fee budget used on one UTC day. Committed counts the
+// maximum fee of transactions still pending and the charged fee of settled
+// ones.
+type FeeDay struct {
+	Day       string `json:"day"`
+	Committed int64  `json:"committed"`
+}
+
+// FeeEstimate is the base fee per operation of each profile, and what is
+// left of the operator's fee budget.
+type FeeEstimate struct {
+	LastLedgerBaseFee   int64                `json:"last_ledger_base_fee"`
+	LedgerCapacityUsage float64              `json:"ledger_capacity_usage"`
+	Profiles            map[FeeProfile]int64 `json:"profiles"`
+	MaxFeePerTx         int64                `json:"max_fee_per_tx"`
+	MaxFeePerDay        int64                `json:"max_fee_per_day"`
+	CommittedToday      int64                `json:"committed_today"`
+}
+
+// FeeStats returns Horizon's recent fee stats, cached for a few seconds.
+func (w *Wallet) FeeStats(ctx context.Context) (horizon.FeeStats, error) {
+	w.feeMu.Lock()
+	defer w.feeMu.Unlock()
+
+	if time.Since(w.feeStatsAt) < feeStatsTTL {
+		return w.feeStats, nil
+	}
+
+	stats, err := w.horizon(ctx).FeeStats()
+	if err != nil {
+		return horizon.FeeStats{}, fmt.Errorf("error fetching fee stats: %w", err)
+	}
+
+	w.feeStats, w.feeStatsAt = stats, time.Now()
+	return stats, nil
+}
+
+// EstimateFee returns the base fee per operation for the profile. It is not
+// capped, see EstimateFees for what the wallet would actually pay.
+func (w *Wallet) EstimateFee(ctx context.Context, profile FeeProfile) (int64, error) {
+	stats, err := w.FeeStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return profileFee(stats, profile), nil
+}
+
+func (w *Wallet) EstimateFees(ctx context.Context) (*FeeEstimate, error) {
+	stats, err := w.FeeStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := w.feeDay(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &FeeEstimate{
+		LastLedgerBaseFee:   stats.LastLedgerBaseFee,
+		LedgerCapacityUsage: stats.LedgerCapacityUsage,
+		Profiles:            map[FeeProfile]int64{},
+		MaxFeePerTx:         w.maxFee,
+		MaxFeePerDay:        w.maxFeePerDay,
+		CommittedToday:      day.Committed,
+	}
+	for _, profile := range []FeeProfile{FeeLow, FeeNormal, FeeHigh} {
+		estimate.Profiles[profile] = min(profileFee(stats, profile), w.maxFee)
+	}
+
+	return estimate, nil
+}
+
+func profileFee(stats horizon.FeeStats, profile FeeProfile) int64 {
+	var fee int64
+	switch profile {
+	case FeeLow:
+		fee = stats.FeeCharged.P10
+	case FeeHigh:
+		// What the most eager senders bid, not what they ended up paying
+		fee = stats.MaxFee.P90
+	default:
+		fee = stats.FeeCharged.P50
+	}
+
+	return max(fee, stats.LastLedgerBaseFee, txnbuild.MinBaseFee)
+}
+
+// estimateFee is the default fee policy. It prices the job by its fee
+// profile and lowers the fee to stay within the per transaction cap.
+func (w *Wallet) estimateFee(ctx context.Context, job *TxJob) (int64, error) {
+	profile := job.FeeProfile
+	if profile == "" {
+		profile = w.feeProfile
+	}
+
+	fee, err := w.EstimateFee(ctx, profile)
+	if err != nil {
+		return 0, err
+	}
+
+	ops := int64(max(len(job.Operations), 1))
+	capped := w.maxFee / ops
+	if capped < txnbuild.MinBaseFee {
+		return 0, fmt.Errorf("%w: %d operations need at least %d stroops", ErrFeeCapExceeded, ops, ops*txnbuild.MinBaseFee)
+	}
+
+	return min(fee, capped), nil
+}
+
+func feeDayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func (w *Wallet) feeDay(t time.Time) (FeeDay, error) {
+	key := feeDayKey(t)
+	day, found, err := w.feeStore.Get(key)
+	if err != nil {
+		return FeeDay{}, err
+	}
+	if !found {
+		day.Day = key
+	}
+	return day, nil
+}
+
+// reserveFee commits the record's maximum fee against the budget of the day
+// it was built, failing if that would exceed either cap.
+func (w *Wallet) reserveFee(rec *TxRecord) error {
+	if rec.MaxFee > w.maxFee {
+		return fmt.Errorf("%w: fee of %d stroops is above the per transaction limit of %d", ErrFeeCapExceeded, rec.MaxFee, w.maxFee)
+	}
+
+	w.budgetMu.Lock()
+	defer w.budgetMu.Unlock()
+
+	day, err := w.feeDay(rec.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if day.Committed+rec.MaxFee > w.maxFeePerDay {
+		return fmt.Errorf("%w: %d of %d stroops already committed today", ErrFeeCapExceeded, day.Committed, w.maxFeePerDay)
+	}
+
+	day.Committed += rec.MaxFee
+	return w.feeStore.Put(day.Day, day)
+}
+
+// refundFee returns the part of a settled record's maximum fee that was not
+// charged to the budget it was reserved from.
+func (w *Wallet) refundFee(rec *TxRecord) {
+	refund := rec.MaxFee - rec.FeeCharged
+	if refund <= 0 {
+		return
+	}
+
+	w.budgetMu.Lock()
+	defer w.budgetMu.Unlock()
+
+	day, err := w.feeDay(rec.CreatedAt)
+	if err == nil {
+		day.Committed = max(day.Committed-refund, 0)
+		err = w.feeStore.Put(day.Day, day)
+	}
+	if err != nil {
+		fmt.Printf("error refunding fee of %s: %v\n", rec.Hash, err)
+	}
+}
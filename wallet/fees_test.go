@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"pi/store"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+)
+
+func TestParseFeeProfile(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FeeProfile
+		wantErr bool
+	}{
+		{"", FeeNormal, false},
+		{"low", FeeLow, false},
+		{"normal", FeeNormal, false},
+		{"high", FeeHigh, false},
+		{"HIGH", "", true},
+		{"urgent", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFeeProfile(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFeeProfile(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func testFeeStats(lastBase, p10, p50, maxP90 int64) horizon.FeeStats {
+	var stats horizon.FeeStats
+	stats.LastLedgerBaseFee = lastBase
+	stats.FeeCharged.P10 = p10
+	stats.FeeCharged.P50 = p50
+	stats.MaxFee.P90 = maxP90
+	return stats
+}
+
+func TestProfileFee(t *testing.T) {
+	busy := testFeeStats(100, 200, 500, 3000)
+	quiet := testFeeStats(100, 100, 100, 100)
+	raised := testFeeStats(1000, 100, 500, 800)
+	empty := testFeeStats(0, 0, 0, 0)
+
+	tests := []struct {
+		name    string
+		stats   horizon.FeeStats
+		profile FeeProfile
+		want    int64
+	}{
+		{"low pays the 10th percentile", busy, FeeLow, 200},
+		{"normal pays the median", busy, FeeNormal, 500},
+		{"high bids the 90th percentile max fee", busy, FeeHigh, 3000},
+		{"unknown profile is normal", busy, "", 500},
+		{"quiet network", quiet, FeeHigh, 100},
+		{"never below the last ledger base fee", raised, FeeHigh, 1000},
+		{"never below the minimum", empty, FeeLow, txnbuild.MinBaseFee},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profileFee(tt.stats, tt.profile); got != tt.want {
+				t.Errorf("profileFee = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateFeeCaps(t *testing.T) {
+	stats := testFeeStats(100, 200, 500, 3000)
+	bumps := func(n int) []txnbuild.Operation {
+		ops := make([]txnbuild.Operation, n)
+		for i := range ops {
+			ops[i] = &txnbuild.BumpSequence{}
+		}
+		return ops
+	}
+
+	tests := []struct {
+		name    string
+		maxFee  int64
+		job     *TxJob
+		want    int64
+		wantErr error
+	}{
+		{"default profile", 1_000_000, &TxJob{Operations: bumps(1)}, 500, nil},
+		{"job profile", 1_000_000, &TxJob{Operations: bumps(1), FeeProfile: FeeHigh}, 3000, nil},
+		{"capped", 1000, &TxJob{Operations: bumps(1), FeeProfile: FeeHigh}, 1000, nil},
+		{"cap shared by operations", 1000, &TxJob{Operations: bumps(4), FeeProfile: FeeHigh}, 250, nil},
+		{"below the cap stays", 1000, &TxJob{Operations: bumps(4), FeeProfile: FeeLow}, 200, nil},
+		{"cap below the minimum", 1000, &TxJob{Operations: bumps(20)}, 0, ErrFeeCapExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Wallet{feeStats: stats, feeStatsAt: time.Now(), feeProfile: FeeNormal, maxFee: tt.maxFee}
+			got, err := w.estimateFee(context.Background(), tt.job)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fee = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserveFee(t *testing.T) {
+	feeStore, err := store.Open[FeeDay](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Wallet{feeStore: feeStore, maxFee: 1000, maxFeePerDay: 2500}
+	now := time.Now()
+
+	steps := []struct {
+		name    string
+		maxFee  int64
+		wantErr error
+		want    int64
+	}{
+		{"within both caps", 1000, nil, 1000},
+		{"above the per transaction cap", 1001, ErrFeeCapExceeded, 1000},
+		{"up to the daily cap", 1000, nil, 2000},
+		{"above the daily cap", 1000, ErrFeeCapExceeded, 2000},
+		{"what is left of the day", 500, nil, 2500},
+	}
+
+	for _, step := range steps {
+		err := w.reserveFee(&TxRecord{MaxFee: step.maxFee, CreatedAt: now})
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		}
+		day, err := w.feeDay(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if day.Committed != step.want {
+			t.Errorf("%s: committed = %d, want %d", step.name, day.Committed, step.want)
+		}
+	}
+
+	// Settling refunds what the transaction wasn't charged
+	w.refundFee(&TxRecord{MaxFee: 1000, FeeCharged: 100, CreatedAt: now})
+	day, err := w.feeDay(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Committed != 1600 {
+		t.Errorf("committed after refund = %d, want 1600", day.Committed)
+	}
+
+	// Other days have a budget of their own
+	if err := w.reserveFee(&TxRecord{MaxFee: 1000, CreatedAt: now.Add(24 * time.Hour)}); err != nil {
+		t.Errorf("next day: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckReadiness verifies that Horizon is reachable, that it serves the
+// network this wallet is configured for, and that the base reserve has been
+// fetched at least once.
+func (w *Wallet) CheckReadiness(ctx context.Context) error {
+	root, err := w.horizon(ctx).Root()
+	if err != nil {
+		return fmt.Errorf("horizon unreachable: %w", err)
+	}
+
+	if root.NetworkPassphrase != w.networkPassphrase {
+		return fmt.Errorf("%w: horizon serves %q, configured %q", ErrNetworkMismatch, root.NetworkPassphrase, w.networkPassphrase)
+	}
+
+	if !w.baseReserveLoaded.Load() {
+		// Retry here so a Horizon outage at startup doesn't keep us unready forever
+		w.GetBaseReserve(ctx)
+		if !w.baseReserveLoaded.Load() {
+			return ErrBaseReserveNotFound
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"pi/metrics"
+	"strings"
+	"time"
+	"unicode"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/xdr"
+)
+
+// instrumentedHTTP wraps the HTTP client used by the Horizon client and
+// records call counts, latency and errors per Horizon endpoint.
+type instrumentedHTTP struct {
+	next     *http.Client
+	basePath string
+}
+
+func newInstrumentedHTTP(next *http.Client, horizonURL string) *instrumentedHTTP {
+	basePath := ""
+	if u, err := url.Parse(horizonURL); err == nil {
+		basePath = strings.TrimSuffix(u.Path, "/")
+	}
+
+	return &instrumentedHTTP{next: next, basePath: basePath}
+}
+
+func (h *instrumentedHTTP) Do(req *http.Request) (*http.Response, error) {
+	endpoint := h.endpoint(req.URL)
+	start := time.Now()
+
+	resp, err := h.next.Do(req)
+
+	metrics.HorizonRequests.WithLabelValues(endpoint, req.Method).Inc()
+	metrics.HorizonRequestDuration.WithLabelValues(endpoint, req.Method).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= 400 {
+		metrics.HorizonErrors.WithLabelValues(endpoint, req.Method).Inc()
+	}
+
+	return resp, err
+}
+
+func (h *instrumentedHTTP) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Do(req)
+}
+
+func (h *instrumentedHTTP) PostForm(rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return h.Do(req)
+}
+
+// endpoint reduces a request path to its top-level Horizon resource so that
+// account IDs and hashes don't blow up the label cardinality.
+func (h *instrumentedHTTP) endpoint(u *url.URL) string {
+	path := strings.TrimPrefix(u.Path, h.basePath)
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "root"
+	}
+
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// contextHTTP ties every request made by a Horizon client to the caller's
+// context. The Horizon client replaces request contexts with its own timeout
+// context, so cancellation of the caller's context is forwarded onto it.
+type contextHTTP struct {
+	ctx  context.Context
+	next hClient.HTTP
+}
+
+func (h contextHTTP) Do(req *http.Request) (*http.Response, error) {
+	if err := h.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	reqCtx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(h.ctx, cancel)
+	// Drop the registration once the Horizon client is done with the request
+	context.AfterFunc(reqCtx, func() { stop() })
+
+	return h.next.Do(req.WithContext(reqCtx))
+}
+
+func (h contextHTTP) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Do(req)
+}
+
+func (h contextHTTP) PostForm(rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return h.Do(req)
+}
+
+// horizon returns a Horizon client whose requests are canceled along with ctx.
+func (w *Wallet) horizon(ctx context.Context) *hClient.Client {
+	return &hClient.Client{
+		HorizonURL: w.serverURL,
+		HTTP:       contextHTTP{ctx: ctx, next: w.http},
+	}
+}
+
+// beginSend counts a submission as in flight, unless the wallet is shutting
+// down and no longer takes new ones.
+func (w *Wallet) beginSend() error {
+	w.inflightMu.Lock()
+	defer w.inflightMu.Unlock()
+
+	if w.draining {
+		return ErrShuttingDown
+	}
+	w.inflight.Add(1)
+	return nil
+}
+
+// WaitForSubmissions refuses new submissions, then blocks until every
+// in-flight one has reached a final state or its confirm timeout, or until
+// ctx is done.
+func (w *Wallet) WaitForSubmissions(ctx context.Context) error {
+	w.inflightMu.Lock()
+	w.draining = true
+	w.inflightMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for in-flight submissions: %w", ctx.Err())
+	}
+}
+
+// resultCodeName converts an XDR result code into the snake_case form Horizon
+// reports, e.g. TransactionResultCodeTxBadSeq becomes tx_bad_seq.
+func resultCodeName(code xdr.TransactionResultCode) string {
+	return snakeCase(strings.TrimPrefix(code.String(), "TransactionResultCode"))
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
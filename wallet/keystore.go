@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"golang.org/x/crypto/scrypt"
+)
+
+// sealedKey is a secret seed encrypted with a key derived from the
+// keystore passphrase.
+type sealedKey struct {
+	Address    string    `json:"address"`
+	Salt       []byte    `json:"salt"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (w *Wallet) keystoreCipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(w.keyPassphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving keystore key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// storeKey keeps kp's secret so the wallet can sign for it unattended.
+func (w *Wallet) storeKey(kp *keypair.Full) error {
+	if w.keyPassphrase == "" {
+		return ErrKeystoreDisabled
+	}
+
+	sealed := sealedKey{
+		Address:   kp.Address(),
+		Salt:      make([]byte, 16),
+		CreatedAt: time.Now(),
+	}
+	rand.Read(sealed.Salt)
+
+	aead, err := w.keystoreCipher(sealed.Salt)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(sealed.Nonce)
+	// The address is authenticated too, a key can't be swapped for another
+	sealed.Ciphertext = aead.Seal(nil, sealed.Nonce, []byte(kp.Seed()), []byte(sealed.Address))
+
+	return w.keyStore.Put(sealed.Address, sealed)
+}
+
+// loadKey returns the stored keypair of address.
+func (w *Wallet) loadKey(address string) (*keypair.Full, error) {
+	if w.keyPassphrase == "" {
+		return nil, ErrKeystoreDisabled
+	}
+
+	sealed, found, err := w.keyStore.Get(address)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, address)
+	}
+
+	aead, err := w.keystoreCipher(sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(sealed.Address))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting key of %s, has the keystore passphrase changed?", address)
+	}
+
+	kp, err := keypair.ParseFull(string(seed))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing stored key of %s: %w", address, err)
+	}
+	if kp.Address() != address {
+		return nil, fmt.Errorf("stored key of %s is for %s", address, kp.Address())
+	}
+	return kp, nil
+}
+
+func (w *Wallet) deleteKey(address string) error {
+	return w.keyStore.Delete(address)
+}
@@ -0,0 +1,315 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+)
+
+// maxSigners is the protocol limit on signers besides the master key.
+const maxSigners = 20
+
+const (
+	ThresholdLow    = "low"
+	ThresholdMedium = "medium"
+	ThresholdHigh   = "high"
+)
+
+// operationThresholds is the threshold each operation type needs. Set
+// options needs high instead when it changes signers, thresholds or the
+// master weight.
+var operationThresholds = map[string]string{
+	"create_account":                   ThresholdMedium,
+	"payment":                          ThresholdMedium,
+	"path_payment_strict_receive":      ThresholdMedium,
+	"path_payment_strict_send":         ThresholdMedium,
+	"manage_sell_offer":                ThresholdMedium,
+	"manage_buy_offer":                 ThresholdMedium,
+	"create_passive_sell_offer":        ThresholdMedium,
+	"set_options":                      ThresholdMedium,
+	"set_options_signers":              ThresholdHigh,
+	"change_trust":                     ThresholdMedium,
+	"allow_trust":                      ThresholdLow,
+	"inflation":                        ThresholdLow,
+	"account_merge":                    ThresholdHigh,
+	"manage_data":                      ThresholdMedium,
+	"bump_sequence":                    ThresholdLow,
+	"create_claimable_balance":         ThresholdMedium,
+	"claim_claimable_balance":          ThresholdLow,
+	"begin_sponsoring_future_reserves": ThresholdMedium,
+	"end_sponsoring_future_reserves":   ThresholdMedium,
+	"revoke_sponsorship":               ThresholdMedium,
+	"clawback":                         ThresholdMedium,
+	"clawback_claimable_balance":       ThresholdMedium,
+	"set_trust_line_flags":             ThresholdLow,
+	"liquidity_pool_deposit":           ThresholdMedium,
+	"liquidity_pool_withdraw":          ThresholdMedium,
+}
+
+// operationThreshold is the threshold op needs according to
+// operationThresholds. Operations missing from it are assumed to need high.
+func operationThreshold(op txnbuild.Operation) string {
+	if so, ok := op.(*txnbuild.SetOptions); ok && (so.Signer != nil || so.MasterWeight != nil || so.LowThreshold != nil || so.MediumThreshold != nil || so.HighThreshold != nil) {
+		return operationThresholds["set_options_signers"]
+	}
+
+	xdrOp, err := op.BuildXDR()
+	if err != nil {
+		return ThresholdHigh
+	}
+	if level, ok := operationThresholds[operations.TypeNames[xdrOp.Body.Type]]; ok {
+		return level
+	}
+	return ThresholdHigh
+}
+
+type AccountSigner struct {
+	Key    string `json:"key"`
+	Weight int32  `json:"weight"`
+	Type   string `json:"type"`
+	Master bool   `json:"master,omitempty"`
+}
+
+type Thresholds struct {
+	Low    uint8 `json:"low"`
+	Medium uint8 `json:"medium"`
+	High   uint8 `json:"high"`
+}
+
+// weight is the signature weight a threshold level needs. A threshold of 0
+// still needs a signature from some signer.
+func (t Thresholds) weight(level string) int32 {
+	var w uint8
+	switch level {
+	case ThresholdLow:
+		w = t.Low
+	case ThresholdMedium:
+		w = t.Medium
+	default:
+		w = t.High
+	}
+	return max(int32(w), 1)
+}
+
+type OperationThreshold struct {
+	Level  string `json:"level"`
+	Weight int32  `json:"weight"`
+}
+
+// AccountInfo is the signing setup of an account.
+type AccountInfo struct {
+	Address     string          `json:"address"`
+	Signers     []AccountSigner `json:"signers"`
+	Thresholds  Thresholds      `json:"thresholds"`
+	TotalWeight int32           `json:"total_weight"`
+	// Operations maps each operation type to the weight it needs
+	Operations map[string]OperationThreshold `json:"operations"`
+}
+
+func newAccountInfo(account horizon.Account) *AccountInfo {
+	info := &AccountInfo{
+		Address: account.AccountID,
+		Signers: []AccountSigner{},
+		Thresholds: Thresholds{
+			Low:    account.Thresholds.LowThreshold,
+			Medium: account.Thresholds.MedThreshold,
+			High:   account.Thresholds.HighThreshold,
+		},
+		Operations: map[string]OperationThreshold{},
+	}
+	for _, s := range account.Signers {
+		info.Signers = append(info.Signers, AccountSigner{
+			Key:    s.Key,
+			Weight: s.Weight,
+			Type:   s.Type,
+			Master: s.Key == account.AccountID,
+		})
+		info.TotalWeight += s.Weight
+	}
+	for op, level := range operationThresholds {
+		info.Operations[op] = OperationThreshold{Level: level, Weight: info.Thresholds.weight(level)}
+	}
+	return info
+}
+
+// signerWeight is the weight of key on the account, 0 if it isn't a signer.
+func (a *AccountInfo) signerWeight(key string) int32 {
+	for _, s := range a.Signers {
+		if s.Key == key {
+			return s.Weight
+		}
+	}
+	return 0
+}
+
+// AccountInfo returns the signers and thresholds of address.
+func (w *Wallet) AccountInfo(ctx context.Context, address string) (*AccountInfo, error) {
+	account, err := w.horizon(ctx).AccountDetail(hClient.AccountRequest{AccountID: address})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching account details: %w", err)
+	}
+	return newAccountInfo(account), nil
+}
+
+type SignerWeight struct {
+	Key string `json:"key"`
+	// Weight 0 removes the signer
+	Weight uint8 `json:"weight"`
+}
+
+// SignerUpdate changes the signing setup of an account. Unset fields are
+// left as they are.
+type SignerUpdate struct {
+	Signers      []SignerWeight `json:"signers"`
+	MasterWeight *uint8         `json:"master_weight"`
+	Low          *uint8         `json:"low_threshold"`
+	Medium       *uint8         `json:"medium_threshold"`
+	High         *uint8         `json:"high_threshold"`
+}
+
+// apply returns the account as it would be after the update, refusing
+// updates that would leave the account unable to sign for itself.
+func (u SignerUpdate) apply(info *AccountInfo) (*AccountInfo, error) {
+	next := &AccountInfo{Address: info.Address, Thresholds: info.Thresholds}
+
+	weights := map[string]int32{}
+	var order []string
+	for _, s := range info.Signers {
+		weights[s.Key] = s.Weight
+		order = append(order, s.Key)
+	}
+
+	seen := map[string]bool{}
+	for _, s := range u.Signers {
+		if seen[s.Key] {
+			return nil, fmt.Errorf("signer %s is listed twice", s.Key)
+		}
+		seen[s.Key] = true
+		if !isSignerKey(s.Key) {
+			return nil, fmt.Errorf("%w: signer %q is not an account, hash-x or pre-auth tx key", ErrInvalidAddress, s.Key)
+		}
+		if s.Key == info.Address {
+			return nil, fmt.Errorf("use master_weight to change the weight of the master key")
+		}
+		if _, ok := weights[s.Key]; !ok {
+			order = append(order, s.Key)
+		}
+		weights[s.Key] = int32(s.Weight)
+	}
+	if u.MasterWeight != nil {
+		weights[info.Address] = int32(*u.MasterWeight)
+	}
+	if u.Low != nil {
+		next.Thresholds.Low = *u.Low
+	}
+	if u.Medium != nil {
+		next.Thresholds.Medium = *u.Medium
+	}
+	if u.High != nil {
+		next.Thresholds.High = *u.High
+	}
+
+	others := 0
+	// Pre-auth transaction signers vanish once used, they can't be relied on
+	var reusable int32
+	for _, key := range order {
+		if weights[key] == 0 {
+			continue
+		}
+		next.Signers = append(next.Signers, AccountSigner{Key: key, Weight: weights[key], Master: key == info.Address})
+		next.TotalWeight += weights[key]
+		if version, _ := strkey.Version(key); version != strkey.VersionByteHashTx {
+			reusable += weights[key]
+		}
+		if key != info.Address {
+			others++
+		}
+	}
+	if others > maxSigners {
+		return nil, fmt.Errorf("an account can have at most %d signers besides its master key", maxSigners)
+	}
+
+	for _, level := range []string{ThresholdLow, ThresholdMedium, ThresholdHigh} {
+		if reusable < next.Thresholds.weight(level) {
+			return nil, fmt.Errorf("%w: signers would weigh %d in total, the %s threshold needs %d", ErrLockout, reusable, level, next.Thresholds.weight(level))
+		}
+	}
+	return next, nil
+}
+
+func isSignerKey(key string) bool {
+	version, err := strkey.Version(key)
+	if err != nil {
+		return false
+	}
+	switch version {
+	case strkey.VersionByteAccountID, strkey.VersionByteHashX, strkey.VersionByteHashTx:
+		_, err = strkey.Decode(version, key)
+		return err == nil
+	}
+	return false
+}
+
+// operations turns the update into set options operations, one per signer
+// as the protocol requires.
+func (u SignerUpdate) operations() []txnbuild.Operation {
+	var ops []txnbuild.Operation
+	for _, s := range u.Signers {
+		ops = append(ops, &txnbuild.SetOptions{
+			Signer: &txnbuild.Signer{Address: s.Key, Weight: txnbuild.Threshold(s.Weight)},
+		})
+	}
+
+	threshold := func(v *uint8) *txnbuild.Threshold {
+		if v == nil {
+			return nil
+		}
+		return txnbuild.NewThreshold(txnbuild.Threshold(*v))
+	}
+	if u.MasterWeight != nil || u.Low != nil || u.Medium != nil || u.High != nil {
+		ops = append(ops, &txnbuild.SetOptions{
+			MasterWeight:    threshold(u.MasterWeight),
+			LowThreshold:    threshold(u.Low),
+			MediumThreshold: threshold(u.Medium),
+			HighThreshold:   threshold(u.High),
+		})
+	}
+	return ops
+}
+
+// UpdateSigners changes the signers and thresholds of kp's account. The
+// change is checked against the account as it is now: it must not lock
+// the account out, and kp alone must meet the high threshold.
+func (w *Wallet) UpdateSigners(ctx context.Context, kp *keypair.Full, update SignerUpdate, profile FeeProfile) (*TxResult, error) {
+	ops := update.operations()
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("nothing to update")
+	}
+
+	account, err := w.GetAccount(ctx, kp)
+	if err != nil {
+		return nil, err
+	}
+	info := newAccountInfo(account)
+
+	if _, err := update.apply(info); err != nil {
+		return nil, err
+	}
+	if have, need := info.signerWeight(kp.Address()), info.Thresholds.weight(ThresholdHigh); have < need {
+		return nil, fmt.Errorf("%w: changing signers needs weight %d, this key has %d", ErrThresholdNotMet, need, have)
+	}
+
+	return w.Run(ctx, &TxJob{
+		Label:      "update signers",
+		Source:     kp,
+		Account:    &account,
+		Operations: ops,
+		FeeProfile: profile,
+	})
+}
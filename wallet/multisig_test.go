@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+)
+
+func signerKey(t *testing.T, version strkey.VersionByte, fill byte) string {
+	t.Helper()
+	key, err := strkey.Encode(version, bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func weight(v uint8) *uint8 {
+	return &v
+}
+
+func TestSignerUpdateApply(t *testing.T) {
+	master := keypair.MustRandom().Address()
+	cosigner := keypair.MustRandom().Address()
+	other := keypair.MustRandom().Address()
+	hashX := signerKey(t, strkey.VersionByteHashX, 1)
+	preAuth := signerKey(t, strkey.VersionByteHashTx, 2)
+
+	// The account as it is: master weight 1, one cosigner of weight 1 and
+	// all thresholds at 1
+	info := &AccountInfo{
+		Address: master,
+		Signers: []AccountSigner{
+			{Key: cosigner, Weight: 1},
+			{Key: master, Weight: 1, Master: true},
+		},
+		Thresholds:  Thresholds{Low: 1, Medium: 1, High: 1},
+		TotalWeight: 2,
+	}
+
+	tooMany := SignerUpdate{}
+	for i := 0; i < maxSigners; i++ {
+		tooMany.Signers = append(tooMany.Signers, SignerWeight{Key: keypair.MustRandom().Address(), Weight: 1})
+	}
+
+	tests := []struct {
+		name        string
+		update      SignerUpdate
+		wantErr     error
+		wantAnyErr  bool
+		wantWeights map[string]int32
+		wantTotal   int32
+	}{
+		{
+			name:        "add a signer",
+			update:      SignerUpdate{Signers: []SignerWeight{{Key: other, Weight: 2}}},
+			wantWeights: map[string]int32{cosigner: 1, master: 1, other: 2},
+			wantTotal:   4,
+		},
+		{
+			name:        "remove a signer",
+			update:      SignerUpdate{Signers: []SignerWeight{{Key: cosigner, Weight: 0}}},
+			wantWeights: map[string]int32{master: 1},
+			wantTotal:   1,
+		},
+		{
+			name:        "reweigh a signer and raise thresholds",
+			update:      SignerUpdate{Signers: []SignerWeight{{Key: cosigner, Weight: 2}}, Medium: weight(2), High: weight(3)},
+			wantWeights: map[string]int32{cosigner: 2, master: 1},
+			wantTotal:   3,
+		},
+		{
+			name:        "disable master with a cosigner left",
+			update:      SignerUpdate{MasterWeight: weight(0)},
+			wantWeights: map[string]int32{cosigner: 1},
+			wantTotal:   1,
+		},
+		{
+			name:        "hash-x signers count",
+			update:      SignerUpdate{Signers: []SignerWeight{{Key: hashX, Weight: 5}}, High: weight(6)},
+			wantWeights: map[string]int32{cosigner: 1, master: 1, hashX: 5},
+			wantTotal:   7,
+		},
+		{
+			name:    "remove every signer",
+			update:  SignerUpdate{Signers: []SignerWeight{{Key: cosigner, Weight: 0}}, MasterWeight: weight(0)},
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "threshold above total weight",
+			update:  SignerUpdate{High: weight(3)},
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "low threshold above total weight",
+			update:  SignerUpdate{Low: weight(10), Medium: weight(1), High: weight(1)},
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "pre-auth signers don't count",
+			update:  SignerUpdate{Signers: []SignerWeight{{Key: preAuth, Weight: 10}}, High: weight(5)},
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "zero threshold still needs a signer",
+			update:  SignerUpdate{Signers: []SignerWeight{{Key: cosigner, Weight: 0}}, MasterWeight: weight(0), Low: weight(0), Medium: weight(0), High: weight(0)},
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "invalid key",
+			update:  SignerUpdate{Signers: []SignerWeight{{Key: "GNOTAKEY", Weight: 1}}},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name:    "secret seed as key",
+			update:  SignerUpdate{Signers: []SignerWeight{{Key: keypair.MustRandom().Seed(), Weight: 1}}},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name:       "duplicate signer",
+			update:     SignerUpdate{Signers: []SignerWeight{{Key: other, Weight: 1}, {Key: other, Weight: 2}}},
+			wantAnyErr: true,
+		},
+		{
+			name:       "master key as signer",
+			update:     SignerUpdate{Signers: []SignerWeight{{Key: master, Weight: 2}}},
+			wantAnyErr: true,
+		},
+		{
+			name:       "too many signers",
+			update:     tooMany,
+			wantAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := tt.update.apply(info)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if next.TotalWeight != tt.wantTotal {
+				t.Errorf("total weight = %d, want %d", next.TotalWeight, tt.wantTotal)
+			}
+			got := map[string]int32{}
+			for _, s := range next.Signers {
+				got[s.Key] = s.Weight
+				if s.Master != (s.Key == master) {
+					t.Errorf("signer %s master = %v", s.Key, s.Master)
+				}
+			}
+			if len(got) != len(tt.wantWeights) {
+				t.Errorf("signers = %v, want %v", got, tt.wantWeights)
+			}
+			for key, w := range tt.wantWeights {
+				if got[key] != w {
+					t.Errorf("weight of %s = %d, want %d", key, got[key], w)
+				}
+			}
+		})
+	}
+
+	// The account itself is left alone
+	if len(info.Signers) != 2 || info.Thresholds.High != 1 {
+		t.Errorf("apply modified the account: %+v", info)
+	}
+}
+
+func TestThresholdsWeight(t *testing.T) {
+	th := Thresholds{Low: 0, Medium: 2, High: 5}
+	tests := []struct {
+		level string
+		want  int32
+	}{
+		{ThresholdLow, 1},
+		{ThresholdMedium, 2},
+		{ThresholdHigh, 5},
+	}
+	for _, tt := range tests {
+		if got := th.weight(tt.level); got != tt.want {
+			t.Errorf("weight(%s) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
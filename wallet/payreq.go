@@ -0,0 +1,402 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/amount"
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/protocols/horizon/operations"
+)
+
+const (
+	PaymentRequestOpen    = "open"
+	PaymentRequestPartial = "partial"
+	PaymentRequestPaid    = "paid"
+	PaymentRequestExpired = "expired"
+)
+
+const (
+	// paymentWatchPoll is how often incoming payments are looked for
+	paymentWatchPoll = 10 * time.Second
+	// maxRequestMessage is the SEP-7 limit on msg
+	maxRequestMessage = 300
+)
+
+// PaymentRequestPlan describes a payment asked of someone else.
+type PaymentRequestPlan struct {
+	Amount     string
+	Message    string
+	ExpiresAt  time.Time
+	WebhookURL string
+}
+
+// ReceivedPayment is an incoming payment matched to a request.
+type ReceivedPayment struct {
+	OperationID string    `json:"operation_id"`
+	TxHash      string    `json:"tx_hash"`
+	From        string    `json:"from"`
+	Amount      string    `json:"amount"`
+	At          time.Time `json:"at"`
+}
+
+// PaymentRequest asks for Amount PI to be paid to Destination with Memo.
+// Payments carrying the memo are added up until the amount is reached or
+// the request expires.
+type PaymentRequest struct {
+	ID          string            `json:"id"`
+	Destination string            `json:"destination"`
+	Amount      string            `json:"amount"`
+	Memo        string            `json:"memo"`
+	Message     string            `json:"message,omitempty"`
+	URI         string            `json:"uri"`
+	Status      string            `json:"status"`
+	Received    string            `json:"received"`
+	Payments    []ReceivedPayment `json:"payments"`
+	WebhookURL  string            `json:"webhook_url,omitempty"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// paymentCursor remembers how far the incoming payments of an account have
+// been matched.
+type paymentCursor struct {
+	Account string `json:"account"`
+	Cursor  string `json:"cursor"`
+}
+
+// PayURI builds a SEP-7 web+stellar:pay URI for a native payment. Empty
+// values are left out.
+func PayURI(destination, amountStr, memo, memoType, message, networkPassphrase string) string {
+	var params []string
+	add := func(key, value string) {
+		if value != "" {
+			// SEP-7 wants percent encoding, spaces as %20 rather than +
+			params = append(params, key+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+		}
+	}
+	add("destination", destination)
+	add("amount", amountStr)
+	add("memo", memo)
+	add("memo_type", memoType)
+	add("msg", message)
+	add("network_passphrase", networkPassphrase)
+
+	return "web+stellar:pay?" + strings.Join(params, "&")
+}
+
+// ValidatePayURI checks that uri is a web+stellar:pay URI with a valid
+// destination and, if given, amount.
+func ValidatePayURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "web+stellar" || u.Opaque != "pay" {
+		return fmt.Errorf("not a web+stellar:pay URI: %q", uri)
+	}
+
+	query := u.Query()
+	if err := ValidateAddress(query.Get("destination")); err != nil {
+		return fmt.Errorf("invalid payment URI destination: %w", err)
+	}
+	if a := query.Get("amount"); a != "" {
+		if stroops, err := amount.ParseInt64(a); err != nil || stroops <= 0 {
+			return fmt.Errorf("invalid payment URI amount %q", a)
+		}
+	}
+	return nil
+}
+
+// CreatePaymentRequest asks for a payment to destination. The request ID
+// doubles as its text memo, which is what incoming payments are matched on.
+func (w *Wallet) CreatePaymentRequest(ctx context.Context, destination string, plan PaymentRequestPlan) (*PaymentRequest, error) {
+	if err := validateAccountAddress(destination); err != nil {
+		return nil, err
+	}
+	stroops, err := amount.ParseInt64(plan.Amount)
+	if err != nil || stroops <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", plan.Amount)
+	}
+	if len(plan.Message) > maxRequestMessage {
+		return nil, fmt.Errorf("message is %d characters, at most %d are allowed", len(plan.Message), maxRequestMessage)
+	}
+	if !plan.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+	if plan.WebhookURL != "" {
+		if err := ValidateWebhookURL(plan.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+
+	w.payReqMu.Lock()
+	defer w.payReqMu.Unlock()
+
+	// Only payments made from now on can settle the request
+	if err := w.startPaymentCursor(ctx, destination); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	req := &PaymentRequest{
+		ID:          hex.EncodeToString(id),
+		Destination: destination,
+		Amount:      amount.StringFromInt64(stroops),
+		Message:     plan.Message,
+		Status:      PaymentRequestOpen,
+		Received:    "0.0000000",
+		Payments:    []ReceivedPayment{},
+		WebhookURL:  plan.WebhookURL,
+		ExpiresAt:   plan.ExpiresAt.UTC().Truncate(time.Second),
+		CreatedAt:   time.Now(),
+	}
+	req.Memo = req.ID
+	req.URI = PayURI(destination, req.Amount, req.Memo, "MEMO_TEXT", req.Message, w.networkPassphrase)
+
+	if err := w.putPaymentRequest(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// startPaymentCursor points the cursor of account at its latest payment,
+// unless it is already being watched.
+func (w *Wallet) startPaymentCursor(ctx context.Context, account string) error {
+	_, found, err := w.cursorStore.Get(account)
+	if err != nil || found {
+		return err
+	}
+
+	page, err := w.horizon(ctx).Payments(hClient.OperationRequest{ForAccount: account, Order: hClient.OrderDesc, Limit: 1})
+	if err != nil && !hClient.IsNotFoundError(err) {
+		return fmt.Errorf("error fetching payments: %w", err)
+	}
+
+	cursor := paymentCursor{Account: account}
+	if len(page.Embedded.Records) > 0 {
+		cursor.Cursor = page.Embedded.Records[0].PagingToken()
+	}
+	return w.cursorStore.Put(account, cursor)
+}
+
+func (w *Wallet) putPaymentRequest(req *PaymentRequest) error {
+	req.UpdatedAt = time.Now()
+	return w.payReqStore.Put(req.ID, *req)
+}
+
+func (w *Wallet) PaymentRequest(id string) (*PaymentRequest, error) {
+	req, found, err := w.payReqStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrRequestNotFound
+	}
+	return &req, nil
+}
+
+// PaymentRequests lists the requests for payments to destination.
+func (w *Wallet) PaymentRequests(destination string) ([]PaymentRequest, error) {
+	all, err := w.payReqStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []PaymentRequest{}
+	for _, req := range all {
+		if req.Destination == destination {
+			requests = append(requests, req)
+		}
+	}
+	return requests, nil
+}
+
+// WatchPaymentRequests matches incoming payments to open requests until ctx
+// is canceled.
+func (w *Wallet) WatchPaymentRequests(ctx context.Context) {
+	ticker := time.NewTicker(paymentWatchPoll)
+	defer ticker.Stop()
+
+	for {
+		if err := w.checkPaymentRequests(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Error checking payment requests: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Wallet) checkPaymentRequests(ctx context.Context) error {
+	w.payReqMu.Lock()
+	defer w.payReqMu.Unlock()
+
+	all, err := w.payReqStore.List()
+	if err != nil {
+		return err
+	}
+
+	// Open requests by destination and memo
+	open := map[string]map[string]*PaymentRequest{}
+	for i := range all {
+		req := &all[i]
+		if req.Status != PaymentRequestOpen && req.Status != PaymentRequestPartial {
+			continue
+		}
+		if open[req.Destination] == nil {
+			open[req.Destination] = map[string]*PaymentRequest{}
+		}
+		open[req.Destination][req.Memo] = req
+	}
+
+	var errs []error
+	for destination, byMemo := range open {
+		if err := w.matchPayments(ctx, destination, byMemo); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", destination, err))
+			continue
+		}
+
+		// Payments are matched first, so one made just before expiry counts
+		for _, req := range byMemo {
+			if req.Status != PaymentRequestPaid && !time.Now().Before(req.ExpiresAt) {
+				w.setPaymentRequestStatus(req, PaymentRequestExpired)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d accounts failed, first: %w", len(errs), errs[0])
+	}
+	return nil
+}
+
+// matchPayments walks the payments to destination since its cursor,
+// crediting those whose memo names an open request.
+func (w *Wallet) matchPayments(ctx context.Context, destination string, byMemo map[string]*PaymentRequest) error {
+	cursor, _, err := w.cursorStore.Get(destination)
+	if err != nil {
+		return err
+	}
+	cursor.Account = destination
+
+	client := w.horizon(ctx)
+	req := hClient.OperationRequest{
+		ForAccount: destination,
+		Cursor:     cursor.Cursor,
+		Limit:      200,
+		Join:       "transactions",
+	}
+	for {
+		page, err := client.Payments(req)
+		if err != nil {
+			return fmt.Errorf("error fetching payments: %w", err)
+		}
+
+		for _, op := range page.Embedded.Records {
+			if err := w.matchPayment(ctx, destination, op, byMemo); err != nil {
+				return err
+			}
+			cursor.Cursor = op.PagingToken()
+		}
+		if err := w.cursorStore.Put(destination, cursor); err != nil {
+			return err
+		}
+
+		if len(page.Embedded.Records) < int(req.Limit) {
+			return nil
+		}
+		req.Cursor = cursor.Cursor
+	}
+}
+
+func (w *Wallet) matchPayment(ctx context.Context, destination string, op operations.Operation, byMemo map[string]*PaymentRequest) error {
+	var base operations.Base
+	payment := ReceivedPayment{}
+	switch op := op.(type) {
+	case operations.Payment:
+		if op.To != destination || op.Asset.Type != "native" {
+			return nil
+		}
+		base, payment.From, payment.Amount = op.Base, op.From, op.Amount
+	case operations.PathPayment:
+		if op.To != destination || op.Asset.Type != "native" {
+			return nil
+		}
+		base, payment.From, payment.Amount = op.Base, op.From, op.Amount
+	case operations.PathPaymentStrictSend:
+		if op.To != destination || op.Asset.Type != "native" {
+			return nil
+		}
+		base, payment.From, payment.Amount = op.Base, op.From, op.Amount
+	case operations.CreateAccount:
+		if op.Account != destination {
+			return nil
+		}
+		base, payment.From, payment.Amount = op.Base, op.Funder, op.StartingBalance
+	default:
+		return nil
+	}
+
+	tx := base.Transaction
+	if tx == nil {
+		// Not every Horizon supports joining transactions
+		detail, err := w.horizon(ctx).TransactionDetail(base.TransactionHash)
+		if err != nil {
+			return fmt.Errorf("error fetching transaction: %w", err)
+		}
+		tx = &detail
+	}
+	if tx.MemoType != "text" {
+		return nil
+	}
+	req := byMemo[tx.Memo]
+	if req == nil || base.LedgerCloseTime.After(req.ExpiresAt) {
+		return nil
+	}
+	for _, p := range req.Payments {
+		if p.OperationID == base.ID {
+			return nil
+		}
+	}
+
+	payment.OperationID = base.ID
+	payment.TxHash = base.TransactionHash
+	payment.At = base.LedgerCloseTime
+	req.Payments = append(req.Payments, payment)
+
+	received, _ := amount.ParseInt64(req.Received)
+	paid, _ := amount.ParseInt64(payment.Amount)
+	want, _ := amount.ParseInt64(req.Amount)
+	received += paid
+	req.Received = amount.StringFromInt64(received)
+
+	status := PaymentRequestPartial
+	if received >= want {
+		status = PaymentRequestPaid
+	}
+	w.setPaymentRequestStatus(req, status)
+	return nil
+}
+
+// setPaymentRequestStatus saves req, notifying its webhook when the status
+// changes.
+func (w *Wallet) setPaymentRequestStatus(req *PaymentRequest, status string) {
+	changed := req.Status != status
+	req.Status = status
+	if err := w.putPaymentRequest(req); err != nil {
+		fmt.Printf("Error saving payment request %s: %v\n", req.ID, err)
+	}
+
+	if changed {
+		w.notify(req.WebhookURL, WebhookEvent{Type: "payment_request." + status, ID: req.ID, At: time.Now(), Data: req})
+	}
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPayURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		amount      string
+		memo        string
+		memoType    string
+		msg         string
+		passphrase  string
+		want        string
+	}{
+		{
+			name:        "destination only",
+			destination: testAccount,
+			want:        "web+stellar:pay?destination=" + testAccount,
+		},
+		{
+			name:        "every field",
+			destination: testAccount,
+			amount:      "12.5",
+			memo:        "a1b2c3",
+			memoType:    "MEMO_TEXT",
+			msg:         "order 42",
+			passphrase:  "Pi Network",
+			want:        "web+stellar:pay?destination=" + testAccount + "&amount=12.5&memo=a1b2c3&memo_type=MEMO_TEXT&msg=order%2042&network_passphrase=Pi%20Network",
+		},
+		{
+			name:        "reserved characters escaped",
+			destination: testAccount,
+			memo:        "a+b&c=d",
+			passphrase:  "Test SDF Network ; September 2015",
+			want:        "web+stellar:pay?destination=" + testAccount + "&memo=a%2Bb%26c%3Dd&network_passphrase=Test%20SDF%20Network%20%3B%20September%202015",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PayURI(tt.destination, tt.amount, tt.memo, tt.memoType, tt.msg, tt.passphrase)
+			if got != tt.want {
+				t.Fatalf("PayURI =\n%s\nwant\n%s", got, tt.want)
+			}
+			if err := ValidatePayURI(got); err != nil {
+				t.Errorf("ValidatePayURI: %v", err)
+			}
+
+			// Values survive a round trip through a standard parser
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := u.Query()
+			for key, want := range map[string]string{"memo": tt.memo, "msg": tt.msg, "network_passphrase": tt.passphrase} {
+				if query.Get(key) != want {
+					t.Errorf("%s = %q, want %q", key, query.Get(key), want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePayURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{"valid", "web+stellar:pay?destination=" + testAccount + "&amount=1", false},
+		{"muxed destination", "web+stellar:pay?destination=" + testMuxed, false},
+		{"no amount", "web+stellar:pay?destination=" + testAccount, false},
+		{"other scheme", "stellar:pay?destination=" + testAccount, true},
+		{"tx operation", "web+stellar:tx?xdr=AAAA", true},
+		{"no destination", "web+stellar:pay?amount=1", true},
+		{"invalid destination", "web+stellar:pay?destination=GNOTAKEY", true},
+		{"zero amount", "web+stellar:pay?destination=" + testAccount + "&amount=0", true},
+		{"negative amount", "web+stellar:pay?destination=" + testAccount + "&amount=-1", true},
+		{"too precise amount", "web+stellar:pay?destination=" + testAccount + "&amount=0.00000001", true},
+		{"not a URI", "://", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePayURI(tt.uri); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+)
+
+// maxOpsPerTx is the protocol limit on operations in one transaction.
+const maxOpsPerTx = 100
+
+// TxJob describes a transaction to run through the pipeline.
+type TxJob struct {
+	// Label names the operation in logs and audit hooks, e.g. "transfer"
+	Label string
+
+	// Source is the transaction source account. It signs the transaction
+	// unless Signers is set.
+	Source  *keypair.Full
+	Signers []*keypair.Full
+
+	// Account is the already loaded source account, saving a Horizon round
+	// trip when the caller fetched it anyway
+	Account *horizon.Account
+
+	Operations []txnbuild.Operation
+	Memo       txnbuild.Memo
+
+	// BaseFee overrides the pipeline fee policy when set. FeeProfile picks
+	// the profile the default policy prices the job with.
+	BaseFee    int64
+	FeeProfile FeeProfile
+
+	// Timeout overrides how long the transaction stays valid, see TX_TIMEOUT
+	Timeout time.Duration
+
+	// LedgerBounds and MinSequence are optional extra preconditions. They
+	// need protocol 19, so are only set for callers that ask for them.
+	LedgerBounds *txnbuild.LedgerBounds
+	MinSequence  *int64
+
+	// NoLedgerWindow skips the TX_LEDGER_WINDOW bound, for transactions that
+	// must stay valid for their whole Timeout, such as ones awaiting cosigners
+	NoLedgerWindow bool
+
+	// DryRun builds and signs the transaction without submitting it
+	DryRun bool
+
+	// Amount and AccountCreated are recorded with the transaction, so a
+	// transfer replayed under its idempotency key reports what it sent
+	Amount         string
+	AccountCreated bool
+
+	// Key identifies the logical operation across retries, see
+	// WithIdempotencyKey. Defaults to the key carried by the context.
+	Key string
+}
+
+// TxResult is the uniform outcome of every transaction the wallet sends.
+type TxResult struct {
+	Hash        string `json:"hash"`
+	Successful  bool   `json:"successful"`
+	Ledger      int32  `json:"ledger,omitempty"`
+	FeeCharged  int64  `json:"fee_charged"`
+	MaxFee      int64  `json:"max_fee"`
+	ResultCode  string `json:"result_code,omitempty"`
+	EnvelopeXDR string `json:"envelope_xdr,omitempty"`
+	ResultXDR   string `json:"result_xdr,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+	// MaxTime is when the transaction stops being valid, as a unix time
+	MaxTime int64 `json:"max_time,omitempty"`
+
+	InnerHash   string `json:"inner_hash,omitempty"`
+	FeeBumpHash string `json:"fee_bump_hash,omitempty"`
+}
+
+type (
+	// FeePolicy picks the base fee for jobs that don't set one.
+	FeePolicy func(ctx context.Context, job *TxJob) (int64, error)
+	// Validator inspects a job before its transaction is built.
+	Validator func(ctx context.Context, job *TxJob) error
+	// AuditHook observes every job once it is finished, successful or not.
+	AuditHook func(ctx context.Context, job *TxJob, res *TxResult, err error)
+	// Submitter sends a recorded transaction to the network, noting what the
+	// network said on the record.
+	Submitter func(ctx context.Context, rec *TxRecord) error
+	// Confirmer waits for a submitted transaction to settle and decides
+	// whether it succeeded.
+	Confirmer func(ctx context.Context, rec *TxRecord) error
+)
+
+// Pipeline is the single path every wallet transaction takes:
+// fee policy, validation, build and sign, dry-run, submission, confirmation
+// and audit. Stages may be replaced or extended at startup, before the
+// wallet is in use.
+type Pipeline struct {
+	FeePolicy  FeePolicy
+	Validators []Validator
+	Submit     Submitter
+	Confirm    Confirmer
+	Audit      []AuditHook
+}
+
+func (w *Wallet) newPipeline() *Pipeline {
+	return &Pipeline{
+		FeePolicy:  w.estimateFee,
+		Validators: []Validator{validateOperations, validateAddresses, w.validateMemoRequired},
+		Submit:     w.sendEnvelope,
+		Confirm:    w.confirmStage,
+		Audit:      []AuditHook{logAudit},
+	}
+}
+
+// Pipeline returns the wallet's transaction pipeline so callers can plug in
+// their own stages.
+func (w *Wallet) Pipeline() *Pipeline {
+	return w.pipeline
+}
+
+// Run builds, signs and submits the job's transaction and waits for its
+// final result. Jobs carrying an idempotency key only build a new
+// transaction once the previous attempt under that key is provably dead.
+func (w *Wallet) Run(ctx context.Context, job *TxJob) (res *TxResult, err error) {
+	p := w.pipeline
+	defer func() {
+		for _, hook := range p.Audit {
+			hook(ctx, job, res, err)
+		}
+	}()
+
+	if job.Source == nil {
+		return nil, errors.New("transaction has no source account")
+	}
+
+	if job.Key == "" {
+		job.Key = idempotencyKeyFrom(ctx)
+	}
+	if job.Key != "" {
+		unlock := w.lockKey(job.Key)
+		defer unlock()
+
+		prev, err := w.previousAttempt(ctx, job.Key)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && (prev.Status == TxSuccess || prev.Status == TxPending) {
+			return prev.result(), prev.err()
+		}
+	}
+
+	if job.Account == nil {
+		account, err := w.GetAccount(ctx, job.Source)
+		if err != nil {
+			return nil, fmt.Errorf("error getting account: %w", err)
+		}
+		job.Account = &account
+	}
+
+	if job.BaseFee == 0 {
+		fee, err := p.FeePolicy(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("error choosing fee: %w", err)
+		}
+		job.BaseFee = fee
+	}
+
+	for _, validate := range p.Validators {
+		if err := validate(ctx, job); err != nil {
+			return nil, err
+		}
+	}
+
+	preconditions, err := w.preconditions(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        job.Account,
+		IncrementSequenceNum: true,
+		Operations:           job.Operations,
+		BaseFee:              job.BaseFee,
+		Memo:                 job.Memo,
+		Preconditions:        preconditions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error building transaction: %w", err)
+	}
+
+	signers := job.Signers
+	if len(signers) == 0 {
+		signers = []*keypair.Full{job.Source}
+	}
+	tx, err = tx.Sign(w.networkPassphrase, signers...)
+	if err != nil {
+		return nil, fmt.Errorf("error signing transaction: %w", err)
+	}
+
+	rec, err := w.newTxRecord(job, tx)
+	if err != nil {
+		return nil, err
+	}
+	if job.DryRun {
+		res := rec.result()
+		res.DryRun = true
+		return res, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return w.send(ctx, rec)
+}
+
+// send submits a signed transaction record through the pipeline's submit
+// and confirm stages.
+func (w *Wallet) send(ctx context.Context, rec *TxRecord) (*TxResult, error) {
+	p := w.pipeline
+
+	if err := w.beginSend(); err != nil {
+		return nil, err
+	}
+	defer w.inflight.Done()
+
+	// Fee caps hold whatever the fee policy decided
+	if err := w.reserveFee(rec); err != nil {
+		return nil, err
+	}
+
+	// Record the attempt before sending it, so a crash mid-submission
+	// still leaves a trace to resolve
+	if err := w.saveTx(rec); err != nil {
+		return nil, err
+	}
+
+	// Once sent, the transaction may be applied whether or not anybody is
+	// listening, so see it through even if the caller gives up
+	sendCtx := context.WithoutCancel(ctx)
+	if err := p.Submit(sendCtx, rec); err != nil {
+		return rec.result(), err
+	}
+	err := p.Confirm(sendCtx, rec)
+
+	return rec.result(), err
+}
+
+// newTxRecord describes a signed transaction that has not been submitted.
+func (w *Wallet) newTxRecord(job *TxJob, tx *txnbuild.Transaction) (*TxRecord, error) {
+	hash, err := tx.HashHex(w.networkPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing transaction: %w", err)
+	}
+
+	envelope, err := tx.Base64()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding transaction: %w", err)
+	}
+
+	rec := &TxRecord{
+		Hash:        hash,
+		Key:         job.Key,
+		Label:       job.Label,
+		Source:      job.Account.AccountID,
+		Sequence:    tx.SequenceNumber(),
+		EnvelopeXDR: envelope,
+		MaxFee:      tx.MaxFee(),
+		MaxTime:     tx.Timebounds().MaxTime,
+		Status:      TxPending,
+		CreatedAt:   time.Now(),
+
+		Amount:         job.Amount,
+		AccountCreated: job.AccountCreated,
+	}
+	if job.LedgerBounds != nil {
+		rec.MaxLedger = job.LedgerBounds.MaxLedger
+	}
+	return rec, nil
+}
+
+func validateOperations(_ context.Context, job *TxJob) error {
+	if len(job.Operations) == 0 {
+		return errors.New("transaction has no operations")
+	}
+	if len(job.Operations) > maxOpsPerTx {
+		return fmt.Errorf("transaction has %d operations, at most %d are allowed", len(job.Operations), maxOpsPerTx)
+	}
+
+	for i, op := range job.Operations {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("invalid operation %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func logAudit(_ context.Context, job *TxJob, res *TxResult, err error) {
+	switch {
+	case err != nil:
+		fmt.Printf("%s failed: %v\n", job.Label, err)
+	case res.DryRun:
+		fmt.Printf("%s built (dry run) - Hash: %s\n", job.Label, res.Hash)
+	default:
+		fmt.Printf("%s successful - Hash: %s, fee charged: %d\n", job.Label, res.Hash, res.FeeCharged)
+	}
+}
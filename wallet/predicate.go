@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// maxPredicateDepth is the protocol limit on nested claim predicates.
+const maxPredicateDepth = 4
+
+// Predicate is a claim predicate tree in a form that is easy to write as
+// JSON. Exactly one field is set:
+//
+//	{"unconditional": true}
+//	{"abs_before": "2026-01-01T00:00:00Z"}
+//	{"rel_before": 3600}
+//	{"and": [p, q]}, {"or": [p, q]}
+//	{"not": p}
+//
+// RelBefore is in seconds since the balance was created.
+type Predicate struct {
+	Unconditional bool        `json:"unconditional,omitempty"`
+	AbsBefore     *time.Time  `json:"abs_before,omitempty"`
+	RelBefore     *int64      `json:"rel_before,omitempty"`
+	And           []Predicate `json:"and,omitempty"`
+	Or            []Predicate `json:"or,omitempty"`
+	Not           *Predicate  `json:"not,omitempty"`
+}
+
+func Unconditional() Predicate {
+	return Predicate{Unconditional: true}
+}
+
+// Before is claimable until t.
+func Before(t time.Time) Predicate {
+	return Predicate{AbsBefore: &t}
+}
+
+// After is claimable from t on.
+func After(t time.Time) Predicate {
+	return Not(Before(t))
+}
+
+// Within is claimable for d after the balance is created.
+func Within(d time.Duration) Predicate {
+	secs := int64(d / time.Second)
+	return Predicate{RelBefore: &secs}
+}
+
+func And(p, q Predicate) Predicate {
+	return Predicate{And: []Predicate{p, q}}
+}
+
+func Or(p, q Predicate) Predicate {
+	return Predicate{Or: []Predicate{p, q}}
+}
+
+func Not(p Predicate) Predicate {
+	return Predicate{Not: &p}
+}
+
+// XDR converts the predicate into its protocol form, checking it is well
+// formed on the way.
+func (p Predicate) XDR() (xdr.ClaimPredicate, error) {
+	return p.toXDR(1)
+}
+
+func (p Predicate) toXDR(depth int) (xdr.ClaimPredicate, error) {
+	if depth > maxPredicateDepth {
+		return xdr.ClaimPredicate{}, fmt.Errorf("%w: nested deeper than %d levels", ErrInvalidPredicate, maxPredicateDepth)
+	}
+
+	set := 0
+	for _, isSet := range []bool{p.Unconditional, p.AbsBefore != nil, p.RelBefore != nil, p.And != nil, p.Or != nil, p.Not != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return xdr.ClaimPredicate{}, fmt.Errorf("%w: exactly one of unconditional, abs_before, rel_before, and, or, not must be set", ErrInvalidPredicate)
+	}
+
+	switch {
+	case p.Unconditional:
+		return txnbuild.UnconditionalPredicate, nil
+	case p.AbsBefore != nil:
+		if p.AbsBefore.Unix() <= 0 {
+			return xdr.ClaimPredicate{}, fmt.Errorf("%w: abs_before must be after 1970", ErrInvalidPredicate)
+		}
+		return txnbuild.BeforeAbsoluteTimePredicate(p.AbsBefore.Unix()), nil
+	case p.RelBefore != nil:
+		if *p.RelBefore < 0 {
+			return xdr.ClaimPredicate{}, fmt.Errorf("%w: rel_before must not be negative", ErrInvalidPredicate)
+		}
+		return txnbuild.BeforeRelativeTimePredicate(*p.RelBefore), nil
+	case p.Not != nil:
+		inner, err := p.Not.toXDR(depth + 1)
+		if err != nil {
+			return xdr.ClaimPredicate{}, err
+		}
+		return txnbuild.NotPredicate(inner), nil
+	}
+
+	name, pair := "and", p.And
+	if p.Or != nil {
+		name, pair = "or", p.Or
+	}
+	if len(pair) != 2 {
+		return xdr.ClaimPredicate{}, fmt.Errorf("%w: %s takes exactly two predicates, got %d", ErrInvalidPredicate, name, len(pair))
+	}
+
+	left, err := pair[0].toXDR(depth + 1)
+	if err != nil {
+		return xdr.ClaimPredicate{}, err
+	}
+	right, err := pair[1].toXDR(depth + 1)
+	if err != nil {
+		return xdr.ClaimPredicate{}, err
+	}
+
+	if name == "and" {
+		return txnbuild.AndPredicate(left, right), nil
+	}
+	return txnbuild.OrPredicate(left, right), nil
+}
+
+// PredicateFromXDR is the inverse of Predicate.XDR.
+func PredicateFromXDR(pred xdr.ClaimPredicate) (Predicate, error) {
+	switch pred.Type {
+	case xdr.ClaimPredicateTypeClaimPredicateUnconditional:
+		return Unconditional(), nil
+	case xdr.ClaimPredicateTypeClaimPredicateBeforeAbsoluteTime:
+		return Before(time.Unix(int64(*pred.AbsBefore), 0).UTC()), nil
+	case xdr.ClaimPredicateTypeClaimPredicateBeforeRelativeTime:
+		secs := int64(*pred.RelBefore)
+		return Predicate{RelBefore: &secs}, nil
+	case xdr.ClaimPredicateTypeClaimPredicateNot:
+		if pred.NotPredicate == nil || *pred.NotPredicate == nil {
+			return Predicate{}, errors.New("not predicate without an operand")
+		}
+		inner, err := PredicateFromXDR(**pred.NotPredicate)
+		if err != nil {
+			return Predicate{}, err
+		}
+		return Not(inner), nil
+	case xdr.ClaimPredicateTypeClaimPredicateAnd, xdr.ClaimPredicateTypeClaimPredicateOr:
+		operands := pred.AndPredicates
+		if pred.Type == xdr.ClaimPredicateTypeClaimPredicateOr {
+			operands = pred.OrPredicates
+		}
+		if operands == nil || len(*operands) != 2 {
+			return Predicate{}, errors.New("and/or predicate without two operands")
+		}
+
+		left, err := PredicateFromXDR((*operands)[0])
+		if err != nil {
+			return Predicate{}, err
+		}
+		right, err := PredicateFromXDR((*operands)[1])
+		if err != nil {
+			return Predicate{}, err
+		}
+		if pred.Type == xdr.ClaimPredicateTypeClaimPredicateOr {
+			return Or(left, right), nil
+		}
+		return And(left, right), nil
+	}
+
+	return Predicate{}, fmt.Errorf("unknown predicate type %d", pred.Type)
+}
+
+// Holds reports whether the predicate allows claiming at t. The network
+// turns relative predicates into absolute ones when a balance is created,
+// so a predicate depending on a relative one is never known to hold.
+func (p Predicate) Holds(t time.Time) bool {
+	holds, known := p.eval(t)
+	return holds && known
+}
+
+func (p Predicate) eval(t time.Time) (holds, known bool) {
+	switch {
+	case p.Unconditional:
+		return true, true
+	case p.AbsBefore != nil:
+		return t.Before(*p.AbsBefore), true
+	case p.Not != nil:
+		holds, known := p.Not.eval(t)
+		return !holds, known
+	case len(p.And) == 2:
+		l, lKnown := p.And[0].eval(t)
+		r, rKnown := p.And[1].eval(t)
+		if (lKnown && !l) || (rKnown && !r) {
+			return false, true
+		}
+		return true, lKnown && rKnown
+	case len(p.Or) == 2:
+		l, lKnown := p.Or[0].eval(t)
+		r, rKnown := p.Or[1].eval(t)
+		if (lKnown && l) || (rKnown && r) {
+			return true, true
+		}
+		return false, lKnown && rKnown
+	}
+	return false, false
+}
@@ -0,0 +1,51 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPredicateEval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		predicate Predicate
+		holds     bool
+		known     bool
+	}{
+		{"unconditional", Unconditional(), true, true},
+		{"before future", Before(future), true, true},
+		{"before past", Before(past), false, true},
+		{"before now is exclusive", Before(now), false, true},
+		{"after past", After(past), true, true},
+		{"after future", After(future), false, true},
+		{"after now is inclusive", After(now), true, true},
+		{"relative is unknown", Within(time.Hour), false, false},
+		{"not relative is unknown", Not(Within(time.Hour)), true, false},
+		{"and both hold", And(After(past), Before(future)), true, true},
+		{"and one fails", And(After(future), Before(future)), false, true},
+		{"and failing side decides over relative", And(Within(time.Hour), Before(past)), false, true},
+		{"and holding side leaves relative unknown", And(Within(time.Hour), Before(future)), true, false},
+		{"or one holds", Or(Before(past), After(past)), true, true},
+		{"or neither holds", Or(Before(past), After(future)), false, true},
+		{"or holding side decides over relative", Or(Within(time.Hour), Unconditional()), true, true},
+		{"or failing side leaves relative unknown", Or(Within(time.Hour), Before(past)), false, false},
+		{"nested", Not(Or(Before(past), And(After(past), Before(future)))), false, true},
+		{"empty", Predicate{}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holds, known := tt.predicate.eval(now)
+			if holds != tt.holds || known != tt.known {
+				t.Errorf("eval = (%v, %v), want (%v, %v)", holds, known, tt.holds, tt.known)
+			}
+			if got, want := tt.predicate.Holds(now), tt.holds && tt.known; got != want {
+				t.Errorf("Holds = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,472 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/keypair"
+)
+
+const (
+	RecurringActive = "active"
+	RecurringPaused = "paused"
+	// RecurringExhausted payments have reached their spending cap
+	RecurringExhausted = "exhausted"
+)
+
+const (
+	// maxRecurringRuns is how much run history is kept per payment
+	maxRecurringRuns = 50
+	// maxRecurringFailures in a row pause a payment
+	maxRecurringFailures = 3
+	// recurringPoll is how often due payments are looked for
+	recurringPoll = 15 * time.Second
+)
+
+// frequencies are the shorthands accepted besides cron expressions.
+var frequencies = map[string]string{
+	"hourly":  "@hourly",
+	"daily":   "@daily",
+	"weekly":  "@weekly",
+	"monthly": "@monthly",
+}
+
+// ParseSchedule parses hourly, daily, weekly, monthly or a standard five
+// field cron expression. Times are UTC unless the expression starts with
+// CRON_TZ=<zone>.
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if descriptor, ok := frequencies[strings.ToLower(spec)]; ok {
+		spec = descriptor
+	}
+	if !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = "CRON_TZ=UTC " + spec
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: it never runs", ErrInvalidSchedule)
+	}
+	if schedule.Next(next).Sub(next) < time.Minute {
+		return nil, fmt.Errorf("%w: runs more often than once a minute", ErrInvalidSchedule)
+	}
+	return schedule, nil
+}
+
+// RecurringPlan describes a payment to repeat on a schedule.
+type RecurringPlan struct {
+	Destination string
+	Amount      string
+	MemoType    string
+	Memo        string
+	Schedule    string
+	// Cap is the most the payment may spend over its lifetime
+	Cap        string
+	WebhookURL string
+	FeeProfile FeeProfile
+}
+
+type RecurringRun struct {
+	At     time.Time `json:"at"`
+	Status string    `json:"status"`
+	Amount string    `json:"amount,omitempty"`
+	TxHash string    `json:"tx_hash,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// RecurringPayment is a persisted recurring payment.
+type RecurringPayment struct {
+	ID          string     `json:"id"`
+	Source      string     `json:"source"`
+	Destination string     `json:"destination"`
+	Amount      string     `json:"amount"`
+	MemoType    string     `json:"memo_type,omitempty"`
+	Memo        string     `json:"memo,omitempty"`
+	Schedule    string     `json:"schedule"`
+	Cap         string     `json:"cap"`
+	Spent       string     `json:"spent"`
+	FeeProfile  FeeProfile `json:"fee_profile"`
+	WebhookURL  string     `json:"webhook_url,omitempty"`
+	Status      string     `json:"status"`
+	// NextRun is only meaningful while the payment is active
+	NextRun   time.Time      `json:"next_run"`
+	Failures  int            `json:"failures"`
+	Runs      []RecurringRun `json:"runs"`
+	LastError string         `json:"last_error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// CreateRecurring schedules a payment from kp. The secret of kp is kept in
+// the keystore so the payment can be signed while nobody is logged in.
+func (w *Wallet) CreateRecurring(ctx context.Context, kp *keypair.Full, plan RecurringPlan) (*RecurringPayment, error) {
+	schedule, err := ParseSchedule(plan.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateAddress(plan.Destination); err != nil {
+		return nil, err
+	}
+	stroops, err := amount.ParseInt64(plan.Amount)
+	if err != nil || stroops <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", plan.Amount)
+	}
+	limit, err := amount.ParseInt64(plan.Cap)
+	if err != nil || limit < stroops {
+		return nil, fmt.Errorf("invalid cap %q: must be at least the amount", plan.Cap)
+	}
+	memo, err := ParseMemo(plan.MemoType, plan.Memo)
+	if err != nil {
+		return nil, err
+	}
+	if plan.WebhookURL != "" {
+		if err := ValidateWebhookURL(plan.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.CheckMemoRequired(ctx, plan.Destination, memo); err != nil {
+		return nil, err
+	}
+
+	// A payment of the same account being deleted must not take the key
+	// with it
+	w.recurringMu.Lock()
+	defer w.recurringMu.Unlock()
+
+	if err := w.storeKey(kp); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	p := &RecurringPayment{
+		ID:          hex.EncodeToString(id),
+		Source:      kp.Address(),
+		Destination: plan.Destination,
+		Amount:      amount.StringFromInt64(stroops),
+		MemoType:    plan.MemoType,
+		Memo:        plan.Memo,
+		Schedule:    plan.Schedule,
+		Cap:         amount.StringFromInt64(limit),
+		Spent:       "0.0000000",
+		FeeProfile:  plan.FeeProfile,
+		WebhookURL:  plan.WebhookURL,
+		Status:      RecurringActive,
+		NextRun:     schedule.Next(time.Now()),
+		Runs:        []RecurringRun{},
+		CreatedAt:   time.Now(),
+	}
+	if err := w.recurringStore.Put(p.ID, *p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Preview lists up to n upcoming runs, stopping where the cap would be
+// reached.
+func (p *RecurringPayment) Preview(n int) ([]time.Time, error) {
+	runs := []time.Time{}
+	if p.Status != RecurringActive {
+		return runs, nil
+	}
+
+	schedule, err := ParseSchedule(p.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	each, _ := amount.ParseInt64(p.Amount)
+	left, _ := amount.ParseInt64(p.Cap)
+	spent, _ := amount.ParseInt64(p.Spent)
+	left -= spent
+
+	next := p.NextRun
+	for len(runs) < n && left >= each {
+		runs = append(runs, next)
+		left -= each
+		next = schedule.Next(next)
+	}
+	return runs, nil
+}
+
+// PreviewSchedule lists the next n runs of spec from now on.
+func PreviewSchedule(spec string, n int) ([]time.Time, error) {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]time.Time, n)
+	next := time.Now()
+	for i := range runs {
+		next = schedule.Next(next)
+		runs[i] = next
+	}
+	return runs, nil
+}
+
+func (w *Wallet) RecurringPayment(id string) (*RecurringPayment, error) {
+	p, found, err := w.recurringStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrRecurringNotFound
+	}
+	return &p, nil
+}
+
+// RecurringPayments lists the recurring payments from source.
+func (w *Wallet) RecurringPayments(source string) ([]RecurringPayment, error) {
+	all, err := w.recurringStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	payments := []RecurringPayment{}
+	for _, p := range all {
+		if p.Source == source {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+// lockRecurring serialises runs and updates of one payment, without
+// holding up the others while a run waits for the network.
+func (w *Wallet) lockRecurring(id string) func() {
+	w.recurringMu.Lock()
+	l, ok := w.recurringLocks[id]
+	if !ok {
+		l = &keyLock{}
+		w.recurringLocks[id] = l
+	}
+	l.refs++
+	w.recurringMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		w.recurringMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(w.recurringLocks, id)
+		}
+		w.recurringMu.Unlock()
+	}
+}
+
+// updateRecurring applies fn to a stored payment while no run of it is in
+// progress.
+func (w *Wallet) updateRecurring(id string, fn func(p *RecurringPayment) error) (*RecurringPayment, error) {
+	unlock := w.lockRecurring(id)
+	defer unlock()
+
+	p, err := w.RecurringPayment(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(p); err != nil {
+		return p, err
+	}
+	return p, w.recurringStore.Put(p.ID, *p)
+}
+
+func (w *Wallet) PauseRecurring(id string) (*RecurringPayment, error) {
+	return w.updateRecurring(id, func(p *RecurringPayment) error {
+		if p.Status == RecurringExhausted {
+			return ErrRecurringExhausted
+		}
+		p.Status = RecurringPaused
+		return nil
+	})
+}
+
+// ResumeRecurring reactivates a paused payment. Runs missed while it was
+// paused are skipped.
+func (w *Wallet) ResumeRecurring(id string) (*RecurringPayment, error) {
+	return w.updateRecurring(id, func(p *RecurringPayment) error {
+		if p.Status == RecurringExhausted {
+			return ErrRecurringExhausted
+		}
+		schedule, err := ParseSchedule(p.Schedule)
+		if err != nil {
+			return err
+		}
+		p.Status = RecurringActive
+		p.Failures = 0
+		p.NextRun = schedule.Next(time.Now())
+		return nil
+	})
+}
+
+// DeleteRecurring removes a payment, and the stored key of its source once
+// no other payment needs it.
+func (w *Wallet) DeleteRecurring(id string) error {
+	unlock := w.lockRecurring(id)
+	defer unlock()
+
+	p, err := w.RecurringPayment(id)
+	if err != nil {
+		return err
+	}
+	if err := w.recurringStore.Delete(id); err != nil {
+		return err
+	}
+
+	w.recurringMu.Lock()
+	defer w.recurringMu.Unlock()
+
+	others, err := w.RecurringPayments(p.Source)
+	if err != nil {
+		return err
+	}
+	if len(others) == 0 {
+		return w.deleteKey(p.Source)
+	}
+	return nil
+}
+
+// RunRecurring sends recurring payments as they come due until ctx is
+// canceled. A payment due while the service was down is sent once on
+// startup; further missed runs are skipped. Payments run side by side, a
+// slow one doesn't hold up the rest.
+func (w *Wallet) RunRecurring(ctx context.Context) {
+	ticker := time.NewTicker(recurringPoll)
+	defer ticker.Stop()
+
+	var running sync.Map
+	for {
+		all, err := w.recurringStore.List()
+		if err != nil {
+			fmt.Printf("Error listing recurring payments: %v\n", err)
+		}
+		for _, p := range all {
+			if ctx.Err() != nil {
+				return
+			}
+			if p.Status != RecurringActive || time.Now().Before(p.NextRun) {
+				continue
+			}
+			if _, busy := running.LoadOrStore(p.ID, true); busy {
+				continue
+			}
+			go func(id string) {
+				defer running.Delete(id)
+				w.runRecurring(ctx, id)
+			}(p.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Wallet) runRecurring(ctx context.Context, id string) {
+	unlock := w.lockRecurring(id)
+	defer unlock()
+
+	// Re-read in case it was paused or deleted since it was listed
+	p, err := w.RecurringPayment(id)
+	if err != nil || p.Status != RecurringActive || time.Now().Before(p.NextRun) {
+		return
+	}
+	schedule, err := ParseSchedule(p.Schedule)
+	if err != nil {
+		p.Status = RecurringPaused
+		p.LastError = err.Error()
+		w.recurringStore.Put(p.ID, *p)
+		return
+	}
+
+	slot := p.NextRun
+	each, _ := amount.ParseInt64(p.Amount)
+	limit, _ := amount.ParseInt64(p.Cap)
+	spent, _ := amount.ParseInt64(p.Spent)
+
+	if spent+each > limit {
+		p.Status = RecurringExhausted
+		w.recurringStore.Put(p.ID, *p)
+		w.notify(p.WebhookURL, WebhookEvent{Type: "recurring.exhausted", ID: p.ID, At: time.Now(), Data: p})
+		return
+	}
+
+	run := RecurringRun{At: time.Now()}
+	res, err := w.sendRecurring(ctx, p, slot)
+	if res != nil {
+		run.TxHash = res.Hash
+		run.Amount = res.Amount
+	}
+	switch {
+	case err == nil:
+		run.Status = PaymentPaid
+	case errors.Is(err, ErrTxPending):
+		run.Status = PaymentPending
+	default:
+		run.Status = PaymentFailed
+		run.Error = err.Error()
+	}
+
+	// A pending payment will most likely go through, it counts against the cap
+	if run.Status != PaymentFailed && res != nil {
+		sent, _ := amount.ParseInt64(res.Amount)
+		p.Spent = amount.StringFromInt64(spent + sent)
+	}
+
+	p.Runs = append(p.Runs, run)
+	if len(p.Runs) > maxRecurringRuns {
+		p.Runs = p.Runs[len(p.Runs)-maxRecurringRuns:]
+	}
+	p.NextRun = schedule.Next(time.Now())
+
+	if run.Status == PaymentFailed {
+		p.Failures++
+		p.LastError = run.Error
+		if p.Failures >= maxRecurringFailures {
+			p.Status = RecurringPaused
+		}
+	} else {
+		p.Failures = 0
+		p.LastError = ""
+	}
+
+	if err := w.recurringStore.Put(p.ID, *p); err != nil {
+		fmt.Printf("Error saving recurring payment %s: %v\n", p.ID, err)
+	}
+
+	if run.Status == PaymentFailed {
+		w.notify(p.WebhookURL, WebhookEvent{Type: "recurring.failed", ID: p.ID, At: run.At, Data: p})
+	}
+}
+
+// sendRecurring makes the payment due at slot. The idempotency key makes
+// sure a slot is never paid twice, even across restarts.
+func (w *Wallet) sendRecurring(ctx context.Context, p *RecurringPayment, slot time.Time) (*TransferResult, error) {
+	kp, err := w.loadKey(p.Source)
+	if err != nil {
+		return nil, err
+	}
+	memo, err := ParseMemo(p.MemoType, p.Memo)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = WithIdempotencyKey(ctx, fmt.Sprintf("recurring-%s-%d", p.ID, slot.Unix()))
+	return w.Transfer(ctx, kp, p.Amount, p.Destination, memo, p.FeeProfile)
+}
@@ -0,0 +1,52 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// A Thursday
+	from := time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "hourly", want: time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "Daily", want: time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "weekly", want: time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{spec: " monthly ", want: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "30 9 * * 1-5", want: time.Date(2026, 1, 16, 9, 30, 0, 0, time.UTC)},
+		{spec: "* * * * *", want: time.Date(2026, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{spec: "CRON_TZ=America/New_York 0 9 * * *", want: time.Date(2026, 1, 15, 14, 0, 0, 0, time.UTC)},
+		{spec: "TZ=Asia/Tokyo 0 0 * * *", want: time.Date(2026, 1, 15, 15, 0, 0, 0, time.UTC)},
+		{spec: "", wantErr: true},
+		{spec: "every day", wantErr: true},
+		{spec: "61 * * * *", wantErr: true},
+		{spec: "0 0 * * * *", wantErr: true},
+		{spec: "CRON_TZ=Nowhere/City 0 0 * * *", wantErr: true},
+		{spec: "@every 30s", wantErr: true},
+		{spec: "0 0 30 2 *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSchedule) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidSchedule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("next run = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
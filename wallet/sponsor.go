@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -8,65 +9,27 @@ import (
 	"github.com/stellar/go/txnbuild"
 )
 
-func (w *Wallet) ClaimBalanceWithSponsor(mainKp, sponsorKp *keypair.Full, balanceID string, fee int64) (string, float64, error) {
-	// Get sponsor account for transaction source
-	sponsorAccount, err := w.GetAccount(sponsorKp)
-	if err != nil {
-		return "", 0, fmt.Errorf("error getting sponsor account: %w", err)
-	}
-
+func (w *Wallet) ClaimBalanceWithSponsor(ctx context.Context, mainKp, sponsorKp *keypair.Full, balanceID string, profile FeeProfile) (*TxResult, error) {
 	// Create claim operation with main account as source
 	claimOp := &txnbuild.ClaimClaimableBalance{
 		BalanceID:     balanceID,
 		SourceAccount: mainKp.Address(),
 	}
 
-	// Build transaction with sponsor as source account
-	tx, err := txnbuild.NewTransaction(
-		txnbuild.TransactionParams{
-			SourceAccount:        &sponsorAccount,
-			IncrementSequenceNum: true,
-			Operations:           []txnbuild.Operation{claimOp},
-			BaseFee:              fee,
-			Preconditions: txnbuild.Preconditions{
-				TimeBounds: txnbuild.NewInfiniteTimeout(),
-			},
-		},
-	)
-	if err != nil {
-		return "", 0, fmt.Errorf("error building transaction: %w", err)
-	}
-
-	// Sign with both keys
-	tx, err = tx.Sign(w.networkPassphrase, sponsorKp, mainKp)
-	if err != nil {
-		return "", 0, fmt.Errorf("error signing transaction: %w", err)
-	}
-
-	// Submit transaction
-	resp, err := w.client.SubmitTransaction(tx)
-	if err != nil {
-		return "", 0, fmt.Errorf("transaction failed: %w", err)
-	}
-
-	// Calculate claimed amount (approximate - you may need to parse from transaction effects)
-	claimedAmount := 1.0 // Default value, should be parsed from transaction results
-	if resp.Successful {
-		claimedAmount = 1.0 // Parse actual amount from transaction effects if needed
-	}
-
-	return resp.Hash, claimedAmount, nil
+	// Sponsor is the transaction source and pays the fee, both keys sign
+	return w.Run(ctx, &TxJob{
+		Label:      "sponsored claim",
+		Source:     sponsorKp,
+		Signers:    []*keypair.Full{sponsorKp, mainKp},
+		Operations: []txnbuild.Operation{claimOp},
+		FeeProfile: profile,
+	})
 }
 
-func (w *Wallet) TransferWithFee(kp *keypair.Full, amountStr, destinationAddr string, fee int64) (string, error) {
-	account, err := w.GetAccount(kp)
-	if err != nil {
-		return "", fmt.Errorf("error getting account: %w", err)
-	}
-
+func (w *Wallet) TransferWithFee(ctx context.Context, kp *keypair.Full, amountStr, destinationAddr string, memo txnbuild.Memo, profile FeeProfile) (*TxResult, error) {
 	amount, err := strconv.ParseFloat(amountStr, 64)
 	if err != nil {
-		return "", fmt.Errorf("invalid amount: %w", err)
+		return nil, fmt.Errorf("invalid amount: %w", err)
 	}
 
 	// Create payment operation
@@ -76,33 +39,11 @@ func (w *Wallet) TransferWithFee(kp *keypair.Full, amountStr, destinationAddr st
 		Asset:       txnbuild.NativeAsset{},
 	}
 
-	// Build transaction with high fee
-	tx, err := txnbuild.NewTransaction(
-		txnbuild.TransactionParams{
-			SourceAccount:        &account,
-			IncrementSequenceNum: true,
-			Operations:           []txnbuild.Operation{paymentOp},
-			BaseFee:              fee,
-			Preconditions: txnbuild.Preconditions{
-				TimeBounds: txnbuild.NewInfiniteTimeout(),
-			},
-		},
-	)
-	if err != nil {
-		return "", fmt.Errorf("error building transaction: %w", err)
-	}
-
-	// Sign transaction
-	tx, err = tx.Sign(w.networkPassphrase, kp)
-	if err != nil {
-		return "", fmt.Errorf("error signing transaction: %w", err)
-	}
-
-	// Submit transaction
-	resp, err := w.client.SubmitTransaction(tx)
-	if err != nil {
-		return "", fmt.Errorf("transaction failed: %w", err)
-	}
-
-	return resp.Hash, nil
-}
\ No newline at end of file
+	return w.Run(ctx, &TxJob{
+		Label:      "transfer",
+		Source:     kp,
+		Operations: []txnbuild.Operation{paymentOp},
+		Memo:       memo,
+		FeeProfile: profile,
+	})
+}
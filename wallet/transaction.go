@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+)
+
+// TransferResult is the outcome of a transfer, with the amount sent and
+// whether the destination account had to be created for it.
+type TransferResult struct {
+	*TxResult
+	Amount         string `json:"amount"`
+	AccountCreated bool   `json:"account_created"`
+}
+
+func (r *TxRecord) transferResult() *TransferResult {
+	return &TransferResult{TxResult: r.result(), Amount: r.Amount, AccountCreated: r.AccountCreated}
+}
+
+// Transfer sends exactly amountStr PI to address, creating the destination
+// account if it doesn't exist yet and the amount covers its minimum balance.
+// A retry under the same idempotency key reports the earlier attempt, even
+// though the balance it spent would no longer cover the transfer.
+func (w *Wallet) Transfer(ctx context.Context, kp *keypair.Full, amountStr string, address string, memo txnbuild.Memo, profile FeeProfile) (*TransferResult, error) {
+	if err := ValidateAddress(address); err != nil {
+		return nil, err
+	}
+
+	// Parse requested amount first
+	requestedAmount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	// Check if amount is too small or negative
+	if requestedAmount <= 0.001 {
+		return nil, fmt.Errorf("amount too small to transfer: %.7f PI", requestedAmount)
+	}
+
+	if key := idempotencyKeyFrom(ctx); key != "" {
+		prev, err := w.previousAttempt(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && (prev.Status == TxSuccess || prev.Status == TxPending) {
+			return prev.transferResult(), prev.err()
+		}
+	}
+
+	w.GetBaseReserve(ctx)
+	baseReserve := w.reservePI()
+
+	// Get account details
+	account, err := w.GetAccount(ctx, kp)
+	if err != nil {
+		return nil, fmt.Errorf("error getting account: %w", err)
+	}
+
+	// Get actual native (PI) balance
+	var nativeBalance float64
+	for _, bal := range account.Balances {
+		if bal.Asset.Type == "native" {
+			nativeBalance, err = strconv.ParseFloat(bal.Balance, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid balance format: %w", err)
+			}
+			break
+		}
+	}
+
+	// Payments to accounts that don't exist fail, those are created instead
+	exists, err := w.accountExists(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if !exists && strkey.IsValidMuxedAccountEd25519PublicKey(address) {
+		return nil, fmt.Errorf("%w: muxed address %s belongs to an account that does not exist yet", ErrDestinationNotFunded, address)
+	}
+
+	// Operations are filled in once the amount is known
+	paymentOp := txnbuild.Payment{
+		Destination: address,
+		Asset:       txnbuild.NativeAsset{},
+	}
+	createOp := txnbuild.CreateAccount{
+		Destination: address,
+	}
+	job := &TxJob{
+		Label:      "transfer",
+		Source:     kp,
+		Account:    &account,
+		Operations: []txnbuild.Operation{&paymentOp},
+		Memo:       memo,
+		FeeProfile: profile,
+	}
+	if !exists {
+		job.Label = "create account"
+		job.Operations = []txnbuild.Operation{&createOp}
+	}
+
+	fee, err := w.pipeline.FeePolicy(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("error choosing fee: %w", err)
+	}
+	job.BaseFee = fee
+
+	// Calculate minimum required balance
+	minBalance := baseReserve * float64(2+account.SubentryCount)
+
+	// Available balance = total - reserve - transaction fee
+	available := nativeBalance - minBalance - float64(fee)/1e7
+	if requestedAmount > available {
+		return nil, fmt.Errorf("%w: %.7f PI requested, %.7f PI available", ErrInsufficientBalance, requestedAmount, max(available, 0))
+	}
+
+	amount := strconv.FormatFloat(requestedAmount, 'f', 7, 64)
+	if exists {
+		paymentOp.Amount = amount
+	} else {
+		// A new account has to start with at least its own minimum balance
+		if minimum := 2 * baseReserve; requestedAmount < minimum {
+			return nil, fmt.Errorf("%w: creating %s needs at least %.7f PI, got %s PI", ErrDestinationNotFunded, address, minimum, amount)
+		}
+		createOp.Amount = amount
+	}
+	job.Amount = amount
+	job.AccountCreated = !exists
+
+	res, err := w.Run(ctx, job)
+	if res == nil {
+		return nil, err
+	}
+
+	// A concurrent retry is answered with the attempt that won the key
+	if rec, found, getErr := w.txStore.Get(res.Hash); getErr == nil && found && rec.Amount != "" {
+		return rec.transferResult(), err
+	}
+	return &TransferResult{TxResult: res, Amount: amount, AccountCreated: !exists}, err
+}
+
+func (w *Wallet) WithdrawClaimableBalance(ctx context.Context, kp *keypair.Full, amountStr, balanceID, address string, memo txnbuild.Memo) (*TxResult, float64, error) {
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error formatting amount: %s", err.Error())
+	}
+	amount = amount - 0.01
+
+	res, err := w.ClaimAndWithdraw(ctx, kp, amount, balanceID, address, memo)
+	if err != nil {
+		return res, amount, fmt.Errorf("error claiming and withdrawing: %w", err)
+	}
+
+	return res, amount, nil
+}
+
+func (w *Wallet) ClaimAndWithdraw(ctx context.Context, kp *keypair.Full, amount float64, balanceID, address string, memo txnbuild.Memo) (*TxResult, error) {
+	claimOp := txnbuild.ClaimClaimableBalance{
+		BalanceID: balanceID,
+	}
+
+	paymentOp := txnbuild.Payment{
+		Destination: address,
+		Amount:      strconv.FormatFloat(amount, 'f', -1, 64),
+		Asset:       txnbuild.NativeAsset{},
+	}
+
+	return w.Run(ctx, &TxJob{
+		Label:      "claim and withdraw",
+		Source:     kp,
+		Operations: []txnbuild.Operation{&claimOp, &paymentOp},
+		Memo:       memo,
+		FeeProfile: FeeHigh,
+	})
+}
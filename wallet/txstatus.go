@@ -0,0 +1,400 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"pi/metrics"
+	"sync"
+	"time"
+
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/protocols/horizon"
+	proto "github.com/stellar/go/protocols/stellarcore"
+)
+
+// txPollInterval is how often a pending transaction is checked while waiting
+// for confirmation. Ledgers close roughly every five seconds.
+const txPollInterval = time.Second
+
+type TxStatus string
+
+const (
+	// TxPending transactions were sent but their outcome is not known yet
+	TxPending TxStatus = "pending"
+	TxSuccess TxStatus = "success"
+	// TxFailed transactions were rejected or failed in a ledger
+	TxFailed TxStatus = "failed"
+	// TxDropped transactions can provably never be applied, because their
+	// sequence number was consumed by another transaction or their time or
+	// ledger bounds passed
+	TxDropped TxStatus = "dropped"
+)
+
+// TxRecord is the persisted status of a transaction built by the wallet.
+type TxRecord struct {
+	Hash           string   `json:"hash"`
+	Key            string   `json:"key,omitempty"`
+	Label          string   `json:"label"`
+	Source         string   `json:"source"`
+	Sequence       int64    `json:"sequence"`
+	EnvelopeXDR    string   `json:"envelope_xdr"`
+	MaxFee         int64    `json:"max_fee"`
+	Status         TxStatus `json:"status"`
+	ResultCode     string   `json:"result_code,omitempty"`
+	OperationCodes []string `json:"operation_codes,omitempty"`
+	ResultXDR      string   `json:"result_xdr,omitempty"`
+	Ledger         int32    `json:"ledger,omitempty"`
+	FeeCharged     int64    `json:"fee_charged,omitempty"`
+	MaxTime        int64    `json:"max_time,omitempty"`
+	MaxLedger      uint32   `json:"max_ledger,omitempty"`
+	Amount         string   `json:"amount,omitempty"`
+	AccountCreated bool     `json:"account_created,omitempty"`
+
+	// InnerHash is set on fee-bump transactions, FeeBumpHash on transactions
+	// that were applied as the inner transaction of a fee bump
+	InnerHash   string `json:"inner_hash,omitempty"`
+	FeeBumpHash string `json:"fee_bump_hash,omitempty"`
+	FeeAccount  string `json:"fee_account,omitempty"`
+
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *TxRecord) Terminal() bool {
+	return r.Status != TxPending
+}
+
+// err describes why the transaction did not succeed, if it didn't.
+func (r *TxRecord) err() error {
+	switch r.Status {
+	case TxFailed:
+		return &TxFailedError{Hash: r.Hash, ResultCode: r.ResultCode, OperationCodes: r.OperationCodes}
+	case TxDropped:
+		return fmt.Errorf("%w: %s", ErrTxDropped, r.Hash)
+	case TxPending:
+		if r.MaxTime != 0 {
+			return fmt.Errorf("%w: %s (valid until %s)", ErrTxPending, r.Hash, time.Unix(r.MaxTime, 0).UTC().Format(time.RFC3339))
+		}
+		return fmt.Errorf("%w: %s", ErrTxPending, r.Hash)
+	}
+	return nil
+}
+
+func (r *TxRecord) result() *TxResult {
+	return &TxResult{
+		Hash:        r.Hash,
+		Successful:  r.Status == TxSuccess,
+		Ledger:      r.Ledger,
+		FeeCharged:  r.FeeCharged,
+		MaxFee:      r.MaxFee,
+		ResultCode:  r.ResultCode,
+		EnvelopeXDR: r.EnvelopeXDR,
+		ResultXDR:   r.ResultXDR,
+		MaxTime:     r.MaxTime,
+		InnerHash:   r.InnerHash,
+		FeeBumpHash: r.FeeBumpHash,
+	}
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey marks every transaction run with the returned context
+// as an attempt at the same logical operation. While an earlier attempt
+// under the key may still be applied, the wallet reports it instead of
+// building a new transaction, so retries can never pay twice.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// loadTxIndex rebuilds the key to latest attempt index from the store.
+func (w *Wallet) loadTxIndex() error {
+	records, err := w.txStore.List()
+	if err != nil {
+		return err
+	}
+
+	latest := map[string]TxRecord{}
+	for _, rec := range records {
+		if rec.Key == "" {
+			continue
+		}
+		if prev, ok := latest[rec.Key]; !ok || rec.CreatedAt.After(prev.CreatedAt) {
+			latest[rec.Key] = rec
+		}
+	}
+
+	for key, rec := range latest {
+		w.txKeys[key] = rec.Hash
+	}
+	return nil
+}
+
+func (w *Wallet) saveTx(rec *TxRecord) error {
+	rec.UpdatedAt = time.Now()
+	if err := w.txStore.Put(rec.Hash, *rec); err != nil {
+		return err
+	}
+
+	if rec.Key != "" {
+		w.txMu.Lock()
+		w.txKeys[rec.Key] = rec.Hash
+		w.txMu.Unlock()
+	}
+	return nil
+}
+
+// settle records the final outcome of a transaction.
+func (w *Wallet) settle(rec *TxRecord, status TxStatus) {
+	rec.Status = status
+	w.refundFee(rec)
+	metrics.ObserveSubmission(status == TxSuccess, rec.ResultCode, rec.FeeCharged)
+}
+
+// lockKey serialises attempts that share an idempotency key.
+func (w *Wallet) lockKey(key string) func() {
+	w.txMu.Lock()
+	l, ok := w.keyLocks[key]
+	if !ok {
+		l = &keyLock{}
+		w.keyLocks[key] = l
+	}
+	l.refs++
+	w.txMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		w.txMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(w.keyLocks, key)
+		}
+		w.txMu.Unlock()
+	}
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// previousAttempt returns the latest transaction built under key, with its
+// status brought up to date.
+func (w *Wallet) previousAttempt(ctx context.Context, key string) (*TxRecord, error) {
+	w.txMu.Lock()
+	hash, ok := w.txKeys[key]
+	w.txMu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	rec, found, err := w.txStore.Get(hash)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	if err := w.refreshTx(ctx, &rec); err != nil {
+		// Without an answer from Horizon we can't prove the attempt dead
+		rec.LastError = err.Error()
+	}
+	return &rec, nil
+}
+
+// Transaction returns the record of a transaction the wallet built, as
+// stored, without asking Horizon about it.
+func (w *Wallet) Transaction(hash string) (*TxRecord, error) {
+	rec, found, err := w.txStore.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTxNotFound
+	}
+	return &rec, nil
+}
+
+// TransactionStatus returns the persisted status of a transaction the wallet
+// built, checking with Horizon first if it is still pending.
+func (w *Wallet) TransactionStatus(ctx context.Context, hash string) (*TxRecord, error) {
+	rec, found, err := w.txStore.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTxNotFound
+	}
+
+	if err := w.refreshTx(ctx, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// refreshTx asks Horizon what became of a pending transaction.
+func (w *Wallet) refreshTx(ctx context.Context, rec *TxRecord) error {
+	if rec.Terminal() {
+		return nil
+	}
+
+	client := w.horizon(ctx)
+	tx, err := client.TransactionDetail(rec.Hash)
+	if err == nil {
+		w.applyTransaction(rec, tx)
+		return w.saveTx(rec)
+	}
+	if !hClient.IsNotFoundError(err) {
+		return fmt.Errorf("error fetching transaction status: %w", err)
+	}
+
+	// Not in a ledger yet. It never will be once the source account's
+	// sequence number has reached ours or its bounds have passed.
+	reason, err := w.deadReason(client, rec)
+	if err != nil || reason == "" {
+		return err
+	}
+
+	// Ours may have been the one that consumed it in the meantime
+	tx, err = client.TransactionDetail(rec.Hash)
+	if err == nil {
+		w.applyTransaction(rec, tx)
+		return w.saveTx(rec)
+	}
+	if !hClient.IsNotFoundError(err) {
+		return fmt.Errorf("error fetching transaction status: %w", err)
+	}
+
+	rec.LastError = reason
+	w.settle(rec, TxDropped)
+	return w.saveTx(rec)
+}
+
+func (w *Wallet) applyTransaction(rec *TxRecord, tx horizon.Transaction) {
+	rec.Ledger = tx.Ledger
+	rec.FeeCharged = tx.FeeCharged
+	if bump := tx.FeeBumpTransaction; bump != nil && bump.Hash != rec.Hash {
+		// Applied inside a fee bump, whose fee account paid instead
+		rec.FeeBumpHash = bump.Hash
+		rec.FeeCharged = 0
+	}
+	rec.ResultXDR = tx.ResultXdr
+	rec.ResultCode, rec.OperationCodes = resultCodes(tx.ResultXdr)
+
+	if tx.Successful {
+		w.settle(rec, TxSuccess)
+	} else {
+		w.settle(rec, TxFailed)
+	}
+}
+
+// sendEnvelope submits the record's envelope, preferring Horizon's async
+// endpoint, and records what Horizon said about it. Errors that leave the
+// outcome unknown are noted on the record and left to confirmation.
+func (w *Wallet) sendEnvelope(ctx context.Context, rec *TxRecord) error {
+	rec.Attempts++
+	client := w.horizon(ctx)
+
+	if !w.asyncUnsupported.Load() {
+		resp, err := client.AsyncSubmitTransactionXDR(rec.EnvelopeXDR)
+		if hErr := hClient.GetError(err); hErr != nil && hErr.Response.StatusCode == http.StatusNotFound {
+			// This Horizon predates the async endpoint
+			w.asyncUnsupported.Store(true)
+		} else {
+			w.applyAsyncResponse(rec, resp, err)
+			return w.saveTx(rec)
+		}
+	}
+
+	tx, err := client.SubmitTransactionXDR(rec.EnvelopeXDR)
+	switch {
+	case err == nil:
+		w.applyTransaction(rec, tx)
+	case rejected(err, rec):
+		w.settle(rec, TxFailed)
+	default:
+		// Timeouts leave the transaction in flight, confirmation finds out
+		rec.LastError = err.Error()
+	}
+
+	return w.saveTx(rec)
+}
+
+func (w *Wallet) applyAsyncResponse(rec *TxRecord, resp horizon.AsyncTransactionSubmissionResponse, err error) {
+	if err != nil {
+		if !rejected(err, rec) {
+			rec.LastError = err.Error()
+			return
+		}
+		w.settle(rec, TxFailed)
+		return
+	}
+
+	switch resp.TxStatus {
+	case proto.TXStatusPending, proto.TXStatusDuplicate:
+		// Accepted into the queue, or already there from an earlier attempt
+	case proto.TXStatusError:
+		rec.ResultXDR = resp.ErrorResultXDR
+		rec.ResultCode, rec.OperationCodes = resultCodes(resp.ErrorResultXDR)
+		w.settle(rec, TxFailed)
+	case proto.TXStatusTryAgainLater:
+		// Core refused to queue it, so it can't be applied
+		rec.ResultCode = "try_again_later"
+		w.settle(rec, TxDropped)
+	}
+}
+
+// rejected reports whether err is Horizon rejecting the transaction with
+// result codes, and copies them onto the record.
+func rejected(err error, rec *TxRecord) bool {
+	hErr := hClient.GetError(err)
+	if hErr == nil {
+		return false
+	}
+
+	codes, cErr := hErr.ResultCodes()
+	if cErr != nil || codes.TransactionCode == "" {
+		return false
+	}
+
+	rec.ResultCode = codes.TransactionCode
+	rec.OperationCodes = codes.OperationCodes
+	if resultXdr, err := hErr.ResultString(); err == nil {
+		rec.ResultXDR = resultXdr
+	}
+	return true
+}
+
+// awaitTx polls a pending transaction until it is settled or the confirm
+// timeout passes.
+func (w *Wallet) awaitTx(ctx context.Context, rec *TxRecord) {
+	ctx, cancel := context.WithTimeout(ctx, w.confirmTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(txPollInterval)
+	defer ticker.Stop()
+
+	for !rec.Terminal() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.refreshTx(ctx, rec); err != nil && ctx.Err() == nil {
+			rec.LastError = err.Error()
+		}
+	}
+}
+
+func (w *Wallet) confirmStage(ctx context.Context, rec *TxRecord) error {
+	w.awaitTx(ctx, rec)
+	return rec.err()
+}
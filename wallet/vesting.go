@@ -0,0 +1,329 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/amount"
+	hClient "github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+)
+
+// maxVestingPeriods keeps a schedule within a handful of transactions.
+const maxVestingPeriods = 1000
+
+// VestingPlan describes a total amount released to a recipient in equal
+// tranches: the first unlocks at Start+Cliff, then one every Interval.
+type VestingPlan struct {
+	Recipient string
+	Total     string
+	Start     time.Time
+	Cliff     time.Duration
+	Periods   int
+	Interval  time.Duration
+}
+
+const (
+	TrancheUnfunded = "unfunded"
+	// TranchePending tranches were sent, but their transaction has not
+	// been confirmed yet
+	TranchePending   = "pending"
+	TrancheLocked    = "locked"
+	TrancheClaimable = "claimable"
+	TrancheClaimed   = "claimed"
+)
+
+type VestingTranche struct {
+	Amount    string    `json:"amount"`
+	UnlocksAt time.Time `json:"unlocks_at"`
+	BalanceID string    `json:"balance_id,omitempty"`
+	TxHash    string    `json:"tx_hash,omitempty"`
+	Status    string    `json:"status"`
+}
+
+// VestingSchedule is a persisted vesting plan and the balances funding it.
+type VestingSchedule struct {
+	ID        string           `json:"id"`
+	Source    string           `json:"source"`
+	Recipient string           `json:"recipient"`
+	Total     string           `json:"total"`
+	Start     time.Time        `json:"start"`
+	Cliff     string           `json:"cliff"`
+	Interval  string           `json:"interval"`
+	Tranches  []VestingTranche `json:"tranches"`
+	LastError string           `json:"last_error,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Tranches splits the plan into its tranches. Amounts are exact, any
+// remainder in stroops goes to the last tranche.
+func (p VestingPlan) Tranches() ([]VestingTranche, error) {
+	if err := validateAccountAddress(p.Recipient); err != nil {
+		return nil, err
+	}
+	if p.Periods < 1 || p.Periods > maxVestingPeriods {
+		return nil, fmt.Errorf("periods must be between 1 and %d", maxVestingPeriods)
+	}
+	if p.Cliff < 0 || (p.Periods > 1 && p.Interval < time.Second) {
+		return nil, errors.New("cliff must not be negative and interval must be at least a second")
+	}
+
+	total, err := amount.ParseInt64(p.Total)
+	if err != nil {
+		return nil, fmt.Errorf("invalid total %q: %w", p.Total, err)
+	}
+	each := total / int64(p.Periods)
+	if each <= 0 {
+		return nil, fmt.Errorf("total %s is too small for %d periods", p.Total, p.Periods)
+	}
+
+	tranches := make([]VestingTranche, p.Periods)
+	for i := range tranches {
+		stroops := each
+		if i == p.Periods-1 {
+			stroops = total - each*int64(p.Periods-1)
+		}
+		tranches[i] = VestingTranche{
+			Amount:    amount.StringFromInt64(stroops),
+			UnlocksAt: p.Start.Add(p.Cliff + time.Duration(i)*p.Interval).UTC().Truncate(time.Second),
+			Status:    TrancheUnfunded,
+		}
+	}
+	return tranches, nil
+}
+
+// CreateVesting funds a vesting plan with one claimable balance per tranche,
+// packed into as few transactions as possible. The schedule is stored as
+// transactions go through, so a failure part way leaves an accurate record
+// of which tranches were funded, and ResumeVesting funds the rest.
+func (w *Wallet) CreateVesting(ctx context.Context, kp *keypair.Full, plan VestingPlan, profile FeeProfile) (*VestingSchedule, error) {
+	tranches, err := plan.Tranches()
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	schedule := &VestingSchedule{
+		ID:        hex.EncodeToString(id),
+		Source:    kp.Address(),
+		Recipient: plan.Recipient,
+		Total:     plan.Total,
+		Start:     plan.Start.UTC(),
+		Cliff:     plan.Cliff.String(),
+		Interval:  plan.Interval.String(),
+		Tranches:  tranches,
+		CreatedAt: time.Now(),
+	}
+
+	release, _ := w.claimVesting(schedule.ID)
+	defer release()
+
+	if err := w.vestingStore.Put(schedule.ID, *schedule); err != nil {
+		return nil, err
+	}
+	return schedule, w.fundVesting(ctx, kp, schedule, profile)
+}
+
+// ResumeVesting funds the tranches of a schedule that aren't funded yet,
+// such as those left behind by a failed or pending transaction. Tranches
+// whose transaction failed since are funded again.
+func (w *Wallet) ResumeVesting(ctx context.Context, kp *keypair.Full, id string, profile FeeProfile) (*VestingSchedule, error) {
+	release, ok := w.claimVesting(id)
+	if !ok {
+		return nil, ErrVestingBusy
+	}
+	defer release()
+
+	schedule, err := w.ownSchedule(kp.Address(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.refreshVesting(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	schedule.LastError = ""
+	return schedule, w.fundVesting(ctx, kp, schedule, profile)
+}
+
+// fundVesting funds the unfunded tranches of a schedule, one transaction
+// per maxOpsPerTx tranches. Each transaction has an idempotency key of its
+// own, so funding a schedule again never funds a tranche twice.
+func (w *Wallet) fundVesting(ctx context.Context, kp *keypair.Full, schedule *VestingSchedule, profile FeeProfile) error {
+	tranches := schedule.Tranches
+	for start := 0; start < len(tranches); start += maxOpsPerTx {
+		chunk := tranches[start:min(start+maxOpsPerTx, len(tranches))]
+		if !unfunded(chunk) {
+			continue
+		}
+
+		balances := make([]*ClaimableBalance, len(chunk))
+		for i, t := range chunk {
+			balances[i] = NewClaimableBalance(t.Amount).To(schedule.Recipient, After(t.UnlocksAt))
+		}
+
+		chunkCtx := WithIdempotencyKey(ctx, fmt.Sprintf("vesting-%s-%d", schedule.ID, start))
+		res, err := w.CreateClaimable(chunkCtx, kp, nil, profile, balances...)
+		if res != nil {
+			// A pending transaction may still create the balances, so its
+			// tranches are recorded too and never funded twice
+			pending := errors.Is(err, ErrTxPending)
+			for i := range chunk {
+				chunk[i].TxHash = res.Hash
+				if (res.Successful || pending) && i < len(res.BalanceIDs) {
+					chunk[i].BalanceID = res.BalanceIDs[i]
+					chunk[i].Status = TranchePending
+					if res.Successful {
+						chunk[i].Status = TrancheLocked
+					}
+				}
+			}
+		}
+		if err != nil {
+			schedule.LastError = err.Error()
+		}
+
+		if putErr := w.vestingStore.Put(schedule.ID, *schedule); putErr != nil {
+			return putErr
+		}
+		if err != nil {
+			return fmt.Errorf("error funding tranches %d to %d: %w", start, start+len(chunk)-1, err)
+		}
+	}
+
+	return nil
+}
+
+// unfunded reports whether none of the tranches of a transaction were
+// funded. A transaction funds all of its tranches or none.
+func unfunded(chunk []VestingTranche) bool {
+	for _, t := range chunk {
+		if t.Status != TrancheUnfunded {
+			return false
+		}
+	}
+	return true
+}
+
+// claimVesting marks a schedule as being funded or refreshed, so nothing
+// else writes it meanwhile. It reports false if it already was.
+func (w *Wallet) claimVesting(id string) (release func(), ok bool) {
+	w.vestingMu.Lock()
+	defer w.vestingMu.Unlock()
+	if w.vestingBusy[id] {
+		return func() {}, false
+	}
+	w.vestingBusy[id] = true
+	return func() {
+		w.vestingMu.Lock()
+		delete(w.vestingBusy, id)
+		w.vestingMu.Unlock()
+	}, true
+}
+
+// ownSchedule loads a schedule funded by source. Schedules of other
+// sources are reported as not found.
+func (w *Wallet) ownSchedule(source, id string) (*VestingSchedule, error) {
+	schedule, found, err := w.vestingStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found || schedule.Source != source {
+		return nil, ErrScheduleNotFound
+	}
+	return &schedule, nil
+}
+
+// VestingSchedule returns a schedule funded by source with each funded
+// tranche's status brought up to date. Schedules of other sources are
+// reported as not found, and left alone. A schedule being funded is
+// returned as stored, it is updated as each transaction goes through.
+func (w *Wallet) VestingSchedule(ctx context.Context, source, id string) (*VestingSchedule, error) {
+	schedule, err := w.ownSchedule(source, id)
+	if err != nil {
+		return nil, err
+	}
+
+	release, ok := w.claimVesting(id)
+	if !ok {
+		return schedule, nil
+	}
+	defer release()
+
+	// Reloaded, funding may have finished in between
+	schedule, err = w.ownSchedule(source, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.refreshVesting(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// refreshVesting brings the status of each funded tranche up to date and
+// stores the schedule.
+func (w *Wallet) refreshVesting(ctx context.Context, schedule *VestingSchedule) error {
+	client := w.horizon(ctx)
+	for i := range schedule.Tranches {
+		t := &schedule.Tranches[i]
+		if t.BalanceID == "" || t.Status == TrancheClaimed {
+			continue
+		}
+
+		// Until its transaction is applied, a missing balance says nothing
+		// about it being claimed
+		if t.Status == TranchePending {
+			rec, err := w.TransactionStatus(ctx, t.TxHash)
+			if err != nil && !errors.Is(err, ErrTxNotFound) {
+				return err
+			}
+			if err != nil || rec.Status == TxPending {
+				continue
+			}
+			if rec.Status != TxSuccess {
+				t.BalanceID = ""
+				t.Status = TrancheUnfunded
+				schedule.LastError = rec.err().Error()
+				continue
+			}
+		}
+
+		_, err := client.ClaimableBalance(t.BalanceID)
+		switch {
+		case hClient.IsNotFoundError(err):
+			t.Status = TrancheClaimed
+		case err != nil:
+			return fmt.Errorf("error fetching claimable balance: %w", err)
+		case time.Now().Before(t.UnlocksAt):
+			t.Status = TrancheLocked
+		default:
+			t.Status = TrancheClaimable
+		}
+	}
+
+	return w.vestingStore.Put(schedule.ID, *schedule)
+}
+
+// VestingSchedules lists the schedules funded by source, without refreshing
+// their status.
+func (w *Wallet) VestingSchedules(source string) ([]VestingSchedule, error) {
+	all, err := w.vestingStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []VestingSchedule{}
+	for _, s := range all {
+		if s.Source == source {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
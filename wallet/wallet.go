@@ -1,10 +1,17 @@
 package wallet
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"net/http"
+	"path/filepath"
+	"pi/config"
+	"pi/store"
 	"pi/util"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/stellar/go/clients/horizonclient"
 	hClient "github.com/stellar/go/clients/horizonclient"
@@ -16,27 +23,158 @@ import (
 type Wallet struct {
 	networkPassphrase string
 	serverURL         string
-	client            *hClient.Client
-	baseReserve       float64
+	http              hClient.HTTP
+	baseReserve       atomic.Int64 // stroops
+	baseReserveLoaded atomic.Bool
+	inflight          sync.WaitGroup
+	inflightMu        sync.Mutex // guards inflight.Add against draining
+	draining          bool
+	pipeline          *Pipeline
+	confirmTimeout    time.Duration
+	txTimeout         time.Duration
+	ledgerWindow      uint32
+	feeProfile        FeeProfile
+	maxFee            int64
+	maxFeePerDay      int64
+	feeAccount        *keypair.Full
+	asyncUnsupported  atomic.Bool
+
+	txStore  *store.Store[TxRecord]
+	txMu     sync.Mutex
+	txKeys   map[string]string
+	keyLocks map[string]*keyLock
+
+	feeMu      sync.Mutex
+	feeStats   horizon.FeeStats
+	feeStatsAt time.Time
+
+	memoMu    sync.Mutex
+	memoCache map[string]memoRequiredEntry
+
+	feeStore *store.Store[FeeDay]
+	budgetMu sync.Mutex
+
+	vestingStore *store.Store[VestingSchedule]
+	vestingMu    sync.Mutex
+	vestingBusy  map[string]bool
+
+	batchStore   *store.Store[Batch]
+	batchMu      sync.Mutex
+	batchRunning map[string]bool
+
+	keyStore      *store.Store[sealedKey]
+	keyPassphrase string
+	webhookSecret string
+
+	recurringStore *store.Store[RecurringPayment]
+	recurringMu    sync.Mutex // guards recurringLocks and stored keys
+	recurringLocks map[string]*keyLock
+
+	payReqStore *store.Store[PaymentRequest]
+	cursorStore *store.Store[paymentCursor]
+	payReqMu    sync.Mutex
+
+	cosignStore *store.Store[PendingTx]
+	cosignMu    sync.Mutex
 }
 
-func New() *Wallet {
-	client := hClient.DefaultPublicNetClient
-	client.HorizonURL = os.Getenv("NET_URL")
+func New(cfg *config.Config) (*Wallet, error) {
+	serverURL := cfg.Network.HorizonURL
+
+	txStore, err := store.Open[TxRecord](filepath.Join(cfg.Storage.DataDir, "transactions"))
+	if err != nil {
+		return nil, err
+	}
+
+	feeStore, err := store.Open[FeeDay](filepath.Join(cfg.Storage.DataDir, "fees"))
+	if err != nil {
+		return nil, err
+	}
+
+	vestingStore, err := store.Open[VestingSchedule](filepath.Join(cfg.Storage.DataDir, "vesting"))
+	if err != nil {
+		return nil, err
+	}
+
+	batchStore, err := store.Open[Batch](filepath.Join(cfg.Storage.DataDir, "batches"))
+	if err != nil {
+		return nil, err
+	}
+
+	keyStore, err := store.Open[sealedKey](filepath.Join(cfg.Storage.DataDir, "keys"))
+	if err != nil {
+		return nil, err
+	}
+
+	recurringStore, err := store.Open[RecurringPayment](filepath.Join(cfg.Storage.DataDir, "recurring"))
+	if err != nil {
+		return nil, err
+	}
+
+	payReqStore, err := store.Open[PaymentRequest](filepath.Join(cfg.Storage.DataDir, "payment-requests"))
+	if err != nil {
+		return nil, err
+	}
+
+	cursorStore, err := store.Open[paymentCursor](filepath.Join(cfg.Storage.DataDir, "payment-cursors"))
+	if err != nil {
+		return nil, err
+	}
+
+	cosignStore, err := store.Open[PendingTx](filepath.Join(cfg.Storage.DataDir, "cosign"))
+	if err != nil {
+		return nil, err
+	}
 
 	w := &Wallet{
-		networkPassphrase: os.Getenv("NET_PASSPHRASE"),
-		serverURL:         os.Getenv("NET_URL"),
-		client:            client,
-		baseReserve:       0.49,
+		networkPassphrase: cfg.Network.Passphrase,
+		serverURL:         serverURL,
+		http:              newInstrumentedHTTP(http.DefaultClient, serverURL),
+		confirmTimeout:    cfg.Tx.ConfirmTimeout,
+		txTimeout:         cfg.Tx.Timeout,
+		ledgerWindow:      cfg.Tx.LedgerWindow,
+		feeProfile:        FeeProfile(cfg.Tx.FeeProfile),
+		maxFee:            cfg.Tx.MaxFee,
+		maxFeePerDay:      cfg.Tx.MaxFeePerDay,
+		txStore:           txStore,
+		feeStore:          feeStore,
+		vestingStore:      vestingStore,
+		vestingBusy:       make(map[string]bool),
+		batchStore:        batchStore,
+		batchRunning:      make(map[string]bool),
+		keyStore:          keyStore,
+		keyPassphrase:     cfg.Storage.KeystorePassphrase,
+		webhookSecret:     cfg.Server.WebhookSecret,
+		recurringStore:    recurringStore,
+		recurringLocks:    make(map[string]*keyLock),
+		payReqStore:       payReqStore,
+		cursorStore:       cursorStore,
+		cosignStore:       cosignStore,
+		memoCache:         make(map[string]memoRequiredEntry),
+		txKeys:            make(map[string]string),
+		keyLocks:          make(map[string]*keyLock),
+	}
+	// 0.49 PI until the network says otherwise
+	w.baseReserve.Store(4_900_000)
+	w.pipeline = w.newPipeline()
+
+	if cfg.Tx.FeeAccountSecret != "" {
+		w.feeAccount, err = util.ParseKey(cfg.Tx.FeeAccountSecret)
+		if err != nil {
+			return nil, fmt.Errorf("error loading fee account: %w", err)
+		}
+	}
+
+	if err := w.loadTxIndex(); err != nil {
+		return nil, err
 	}
-	w.GetBaseReserve()
+	w.GetBaseReserve(context.Background())
 
-	return w
+	return w, nil
 }
 
-func (w *Wallet) GetBaseReserve() {
-	ledger, err := w.client.Ledgers(horizonclient.LedgerRequest{Order: horizonclient.OrderDesc, Limit: 1})
+func (w *Wallet) GetBaseReserve(ctx context.Context) {
+	ledger, err := w.horizon(ctx).Ledgers(horizonclient.LedgerRequest{Order: horizonclient.OrderDesc, Limit: 1})
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -48,8 +186,26 @@ func (w *Wallet) GetBaseReserve() {
 	}
 
 	baseReserveStr := ledger.Embedded.Records[0].BaseReserve
-	w.baseReserve = float64(baseReserveStr) / 1e7
-	fmt.Printf("Base reserve: %.7f\n", w.baseReserve)
+	w.baseReserve.Store(int64(baseReserveStr))
+	w.baseReserveLoaded.Store(true)
+	fmt.Printf("Base reserve: %.7f\n", w.reservePI())
+}
+
+// loadedBaseReserve returns the base reserve in stroops, fetching it only
+// if it has never been loaded.
+func (w *Wallet) loadedBaseReserve(ctx context.Context) (int64, error) {
+	if !w.baseReserveLoaded.Load() {
+		w.GetBaseReserve(ctx)
+		if !w.baseReserveLoaded.Load() {
+			return 0, ErrBaseReserveNotFound
+		}
+	}
+	return w.baseReserve.Load(), nil
+}
+
+// reservePI is the last known base reserve in PI.
+func (w *Wallet) reservePI() float64 {
+	return float64(w.baseReserve.Load()) / 1e7
 }
 
 func (w *Wallet) GetAddress(kp *keypair.Full) string {
@@ -65,9 +221,9 @@ func (w *Wallet) Login(seedPhrase string) (*keypair.Full, error) {
 	return kp, nil
 }
 
-func (w *Wallet) GetAccount(kp *keypair.Full) (horizon.Account, error) {
+func (w *Wallet) GetAccount(ctx context.Context, kp *keypair.Full) (horizon.Account, error) {
 	accReq := hClient.AccountRequest{AccountID: kp.Address()}
-	account, err := w.client.AccountDetail(accReq)
+	account, err := w.horizon(ctx).AccountDetail(accReq)
 	if err != nil {
 		return horizon.Account{}, fmt.Errorf("error fetching account details: %v", err)
 	}
@@ -75,8 +231,8 @@ func (w *Wallet) GetAccount(kp *keypair.Full) (horizon.Account, error) {
 	return account, nil
 }
 
-func (w *Wallet) GetAvailableBalance(kp *keypair.Full) (string, error) {
-	account, err := w.GetAccount(kp)
+func (w *Wallet) GetAvailableBalance(ctx context.Context, kp *keypair.Full) (string, error) {
+	account, err := w.GetAccount(ctx, kp)
 	if err != nil {
 		return "", err
 	}
@@ -92,7 +248,7 @@ func (w *Wallet) GetAvailableBalance(kp *keypair.Full) (string, error) {
 		}
 	}
 
-	reserve := w.baseReserve * float64(2+account.SubentryCount)
+	reserve := w.reservePI() * float64(2+account.SubentryCount)
 	available := totalBalance - reserve
 	if available < 0 {
 		available = 0
@@ -103,14 +259,14 @@ func (w *Wallet) GetAvailableBalance(kp *keypair.Full) (string, error) {
 	return availableStr, nil
 }
 
-func (w *Wallet) GetTransactions(kp *keypair.Full, limit uint) ([]operations.Operation, error) {
+func (w *Wallet) GetTransactions(ctx context.Context, kp *keypair.Full, limit uint) ([]operations.Operation, error) {
 	req := horizonclient.OperationRequest{
 		ForAccount: kp.Address(),
 		Limit:      limit,
 		Order:      horizonclient.OrderDesc,
 	}
 
-	ops, err := w.client.Operations(req)
+	ops, err := w.horizon(ctx).Operations(req)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching transactions: %w", err)
 	}
@@ -118,12 +274,12 @@ func (w *Wallet) GetTransactions(kp *keypair.Full, limit uint) ([]operations.Ope
 	return ops.Embedded.Records, nil
 }
 
-func (w *Wallet) GetLockedBalances(kp *keypair.Full) ([]horizon.ClaimableBalance, error) {
+func (w *Wallet) GetLockedBalances(ctx context.Context, kp *keypair.Full) ([]horizon.ClaimableBalance, error) {
 	req := horizonclient.ClaimableBalanceRequest{
 		Claimant: kp.Address(),
 	}
 
-	balances, err := w.client.ClaimableBalances(req)
+	balances, err := w.horizon(ctx).ClaimableBalances(req)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching locked balances: %w", err)
 	}
@@ -131,8 +287,8 @@ func (w *Wallet) GetLockedBalances(kp *keypair.Full) ([]horizon.ClaimableBalance
 	return balances.Embedded.Records, nil
 }
 
-func (w *Wallet) GetClaimableBalance(balanceID string) (*horizon.ClaimableBalance, error) {
-	balance, err := w.client.ClaimableBalance(balanceID)
+func (w *Wallet) GetClaimableBalance(ctx context.Context, balanceID string) (*horizon.ClaimableBalance, error) {
+	balance, err := w.horizon(ctx).ClaimableBalance(balanceID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching claimable balance: %w", err)
 	}
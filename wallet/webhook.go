@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// webhookAttempts is how many times an event is delivered before giving up.
+const webhookAttempts = 3
+
+// WebhookEvent is the JSON body POSTed to webhooks.
+type WebhookEvent struct {
+	Type string    `json:"type"`
+	ID   string    `json:"id"`
+	At   time.Time `json:"at"`
+	Data any       `json:"data"`
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, not routable on the
+// internet either.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is reachable on the internet. Webhooks never
+// go to loopback, private or link-local addresses, such as the cloud
+// metadata service at 169.254.169.254.
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// ValidateWebhookURL checks that url is an absolute http(s) URL whose host
+// resolves to public addresses only.
+func ValidateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook must be an absolute http(s) URL, got %q", rawURL)
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("error resolving webhook host: %w", err)
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return fmt.Errorf("webhook host %s resolves to %s, which is not a public address", u.Hostname(), ip)
+		}
+	}
+	return nil
+}
+
+// webhookClient checks addresses again as it connects, the host may
+// resolve differently by the time an event is delivered.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return errors.New("webhook address " + host + " is not public")
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	// A redirect must not lead anywhere a webhook couldn't be registered for
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// notify delivers event to url in the background, retrying failed
+// deliveries. With a webhook secret configured the body is signed, the
+// X-Signature header holds its hex encoded HMAC-SHA256.
+func (w *Wallet) notify(url string, event WebhookEvent) {
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("Webhook %s: error encoding event: %v\n", event.Type, err)
+		return
+	}
+
+	go func() {
+		for attempt := 1; attempt <= webhookAttempts; attempt++ {
+			err = w.deliver(webhookClient, url, body)
+			if err == nil {
+				return
+			}
+			if attempt < webhookAttempts {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}
+		fmt.Printf("Webhook %s to %s failed: %v\n", event.Type, url, err)
+	}()
+}
+
+func (w *Wallet) deliver(client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.webhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(w.webhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}